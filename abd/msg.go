@@ -20,22 +20,22 @@ type Get struct {
 	Key paxi.Key
 }
 
-// GetReply message returns value and version
+// GetReply message returns value and its tag
 type GetReply struct {
-	ID      paxi.ID
-	CID     int
-	Key     paxi.Key
-	Value   paxi.Value
-	Version int
+	ID    paxi.ID
+	CID   int
+	Key   paxi.Key
+	Value paxi.Value
+	Tag   paxi.Ballot
 }
 
 // Set message
 type Set struct {
-	ID      paxi.ID
-	CID     int
-	Key     paxi.Key
-	Value   paxi.Value
-	Version int
+	ID    paxi.ID
+	CID   int
+	Key   paxi.Key
+	Value paxi.Value
+	Tag   paxi.Ballot
 }
 
 // SetReply acknowledges a set operation, whether succeed or not
@@ -15,30 +15,37 @@ const (
 )
 
 type entry struct {
-	r         *paxi.Request
+	command   paxi.Command
+	reply     func(paxi.Reply)
 	state     state
 	getQuorum *paxi.Quorum
 	setQuorum *paxi.Quorum
 	value     paxi.Value
-	version   int
+	tag       paxi.Ballot
 }
 
-// Replica implements ABD atomic storage protocol
-// Each read and write operation proceed in Get and Set phase
+// Replica implements multi-writer ABD atomic storage protocol
+// Each read and write operation proceed in Get and Set phase.
+// Values are ordered by tag of (counter, writer id), so concurrent writers never pick the same tag.
 type Replica struct {
 	paxi.Node
 	cid int
 
-	log     map[int]*entry
-	version map[paxi.Key]int
+	log map[int]*entry
+	tag map[paxi.Key]paxi.Ballot
 }
 
 // NewReplica generates ABD replica
 func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates ABD replica on node n
+func newReplica(n paxi.Node) *Replica {
 	r := new(Replica)
-	r.Node = paxi.NewNode(id)
+	r.Node = n
 	r.log = make(map[int]*entry)
-	r.version = make(map[paxi.Key]int)
+	r.tag = make(map[paxi.Key]paxi.Ballot)
 	r.Register(paxi.Request{}, r.handleRequest)
 	r.Register(Get{}, r.handleGet)
 	r.Register(GetReply{}, r.handleGetReply)
@@ -49,18 +56,22 @@ func NewReplica(id paxi.ID) *Replica {
 
 func (r *Replica) handleRequest(m paxi.Request) {
 	log.Debugf("Node %s received Request %v", r.ID(), m)
-	k := m.Command.Key
-	v := r.Get(k)
-	version := r.version[k]
+	r.request(m.Command, m.Reply)
+}
+
+// request starts Get phase of command c and calls reply once done
+func (r *Replica) request(c paxi.Command, reply func(paxi.Reply)) {
+	k := c.Key
 	r.cid++
 	// entry save my local verion of value
 	r.log[r.cid] = &entry{
-		r:         &m,
+		command:   c,
+		reply:     reply,
 		state:     GetPhase,
 		getQuorum: paxi.NewQuorum(),
 		setQuorum: paxi.NewQuorum(),
-		value:     v,
-		version:   version,
+		value:     r.Get(k),
+		tag:       r.tag[k],
 	}
 	r.log[r.cid].getQuorum.ACK(r.ID())
 	r.Broadcast(Get{
@@ -73,19 +84,19 @@ func (r *Replica) handleRequest(m paxi.Request) {
 func (r *Replica) handleGet(m Get) {
 	v := r.Node.Get(m.Key)
 	r.Send(m.ID, GetReply{
-		ID:      r.ID(),
-		CID:     m.CID,
-		Key:     m.Key,
-		Value:   v,
-		Version: r.version[m.Key],
+		ID:    r.ID(),
+		CID:   m.CID,
+		Key:   m.Key,
+		Value: v,
+		Tag:   r.tag[m.Key],
 	})
 }
 
 func (r *Replica) handleSet(m Set) {
-	if m.Version > r.version[m.Key] {
+	if m.Tag > r.tag[m.Key] {
 		// update local value
 		r.Node.Put(m.Key, m.Value)
-		r.version[m.Key] = m.Version
+		r.tag[m.Key] = m.Tag
 	}
 	r.Send(m.ID, SetReply{
 		ID:  r.ID(),
@@ -95,63 +106,70 @@ func (r *Replica) handleSet(m Set) {
 }
 
 func (r *Replica) handleGetReply(m GetReply) {
-	e := r.log[m.CID]
-	if e.state != GetPhase {
+	e, exists := r.log[m.CID]
+	if !exists || e.state != GetPhase {
 		return
 	}
-	if m.Version > e.version {
+	if m.Tag > e.tag {
 		e.value = m.Value
-		e.version = m.Version
+		e.tag = m.Tag
 		// update local value
-		r.Node.Put(m.Key, m.Value)
-		r.version[m.Key] = m.Version
+		if m.Tag > r.tag[m.Key] {
+			r.Node.Put(m.Key, m.Value)
+			r.tag[m.Key] = m.Tag
+		}
 	}
 	e.getQuorum.ACK(m.ID)
 	if e.getQuorum.Majority() {
 		e.state = SetPhase // into set phase
 		e.setQuorum.ACK(r.ID())
-		if e.r.Command.IsRead() {
+		if e.command.IsRead() {
 			r.Broadcast(Set{
-				ID:      r.ID(),
-				CID:     m.CID,
-				Key:     m.Key,
-				Value:   e.value,
-				Version: e.version,
+				ID:    r.ID(),
+				CID:   m.CID,
+				Key:   m.Key,
+				Value: e.value,
+				Tag:   e.tag,
 			})
 		} else {
-			e.value = e.r.Command.Value
-			e.version++
+			// tag is higher than any tag seen by quorum or written locally by concurrent writes
+			if r.tag[m.Key] > e.tag {
+				e.tag = r.tag[m.Key]
+			}
+			e.tag.Next(r.ID())
+			e.value = e.command.Value
 			// write new value to local database first
-			r.Node.Put(e.r.Command.Key, e.r.Command.Value)
-			r.version[m.Key] = e.version
+			r.Node.Put(e.command.Key, e.command.Value)
+			r.tag[m.Key] = e.tag
 			r.Broadcast(Set{
-				ID:      r.ID(),
-				CID:     m.CID,
-				Key:     e.r.Command.Key,
-				Value:   e.r.Command.Value,
-				Version: e.version,
+				ID:    r.ID(),
+				CID:   m.CID,
+				Key:   e.command.Key,
+				Value: e.command.Value,
+				Tag:   e.tag,
 			})
 		}
 	}
 }
 
 func (r *Replica) handleSetReply(m SetReply) {
-	e := r.log[m.CID]
-	if e.state != SetPhase {
+	e, exists := r.log[m.CID]
+	if !exists || e.state != SetPhase {
 		return
 	}
 	e.setQuorum.ACK(m.ID)
 	if e.setQuorum.Majority() {
 		e.state = Done
-		if e.r.Command.IsRead() {
-			e.r.Reply(paxi.Reply{
-				Command: e.r.Command,
+		if e.command.IsRead() {
+			e.reply(paxi.Reply{
+				Command: e.command,
 				Value:   e.value,
 			})
 		} else {
-			e.r.Reply(paxi.Reply{
-				Command: e.r.Command,
+			e.reply(paxi.Reply{
+				Command: e.command,
 			})
 		}
+		delete(r.log, m.CID)
 	}
 }
@@ -0,0 +1,109 @@
+package abd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+var ids = []paxi.ID{"1.1", "1.2", "1.3"}
+
+func cluster(t *testing.T) (map[paxi.ID]*paxitest.Node, map[paxi.ID]*Replica) {
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	replicas := make(map[paxi.ID]*Replica)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+	return nodes, replicas
+}
+
+func TestReadWriteBack(t *testing.T) {
+	nodes, _ := cluster(t)
+	get := func(id paxi.ID) (v paxi.Value) {
+		nodes[id].Do(func() { v = nodes[id].Get(1) })
+		return
+	}
+
+	// write of 1.1 is stored locally but its Set phase never reaches other replicas
+	nodes["1.1"].Lost = func(to paxi.ID, m interface{}) bool {
+		_, ok := m.(Set)
+		return ok
+	}
+	nodes["1.1"].Request(paxi.Command{Key: 1, Value: paxi.Value("a")})
+	paxitest.Eventually(t, func() error {
+		if get("1.1") == nil {
+			return errors.New("incomplete write is not stored by writer")
+		}
+		return nil
+	})
+
+	// read of 1.2 from quorum of 1.1 and itself returns the incomplete write, and writes it back to 1.3
+	nodes["1.3"].Lost = func(to paxi.ID, m interface{}) bool {
+		_, ok := m.(GetReply)
+		return ok && to == "1.2"
+	}
+	if v := nodes["1.2"].Call(t, paxi.Command{Key: 1}).Value; string(v) != "a" {
+		t.Errorf("read of incomplete write = %q, expected a", v)
+	}
+	paxitest.Eventually(t, func() error {
+		if v := get("1.3"); !bytes.Equal(v, paxi.Value("a")) {
+			return fmt.Errorf("replica 1.3 has value %q, read did not write back a", v)
+		}
+		return nil
+	})
+
+	// later read without the writer never returns the older value
+	nodes["1.1"].Fail(true)
+	if v := nodes["1.3"].Call(t, paxi.Command{Key: 1}).Value; string(v) != "a" {
+		t.Errorf("read after write back = %q, expected a", v)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	nodes, replicas := cluster(t)
+	state := func(id paxi.ID) (v paxi.Value, tag paxi.Ballot) {
+		nodes[id].Do(func() { v, tag = nodes[id].Get(1), replicas[id].tag[1] })
+		return
+	}
+
+	// concurrent writers never pick the same tag, every replica ends with the value of the highest tag
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id paxi.ID) {
+			defer wg.Done()
+			if r := <-nodes[id].Request(paxi.Command{Key: 1, Value: paxi.Value(strconv.Itoa(i))}); r.Err != nil {
+				t.Error(r.Err)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	paxitest.Eventually(t, func() error {
+		v, tag := state(ids[0])
+		for _, id := range ids[1:] {
+			if x, y := state(id); !bytes.Equal(x, v) || y != tag {
+				return fmt.Errorf("replica %s has value %q of tag %v, %s has %q of tag %v", id, x, y, ids[0], v, tag)
+			}
+		}
+		return nil
+	})
+	v, tag := state(ids[0])
+	if i, _ := strconv.Atoi(string(v)); tag.ID() != ids[i] {
+		t.Errorf("value %q is written with tag %v of another writer", v, tag)
+	}
+}
+
+func TestLinearizable(t *testing.T) {
+	nodes, _ := cluster(t)
+	paxitest.Linearizable(t, []*paxitest.Node{nodes["1.1"], nodes["1.2"], nodes["1.3"]}, 9, 100)
+}
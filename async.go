@@ -0,0 +1,307 @@
+package paxi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ailidani/paxi/log"
+)
+
+// ErrClosed is returned for commands issued to or pending on a closed AsyncClient
+var ErrClosed = errors.New("client connection closed")
+
+// ErrTimeout is returned for pipelined commands without reply within config.ClientTimeout
+var ErrTimeout = errors.New("client command timeout")
+
+// Future is the pending result of an asynchronous command
+type Future struct {
+	done  chan struct{}
+	value Value
+	err   error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(v Value, err error) {
+	f.value = v
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel closed when the command completes
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get blocks until the command completes and returns its result
+func (f *Future) Get() (Value, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Then calls f with the result once the command completes
+func (f *Future) Then(callback func(Value, error)) {
+	go func() {
+		callback(f.Get())
+	}()
+}
+
+// pipeline pipelines commands of client sessions over a single connection to one replica,
+// any number of commands can be outstanding and complete in any order.
+// It only keeps the outstanding commands, the codec of the connection encodes commands and decodes replies
+type pipeline struct {
+	*clientSessions
+	name  string // for logging
+	conn  net.Conn
+	codec pipelineCodec
+
+	sync.Mutex
+	pending map[string]*call
+	err     error // set once connection fails
+}
+
+// pipelineCodec encodes commands to and decodes replies from a pipelined connection,
+// encode is called by one goroutine at a time and decode by the receiving goroutine only
+type pipelineCodec interface {
+	encode(Command) error
+	decode() (Reply, error)
+}
+
+// call is an outstanding command and the session it holds
+type call struct {
+	future  *Future
+	session *clientSession
+	timer   *time.Timer // fails the call at its deadline
+}
+
+func newPipeline(id ID, name string) pipeline {
+	return pipeline{
+		clientSessions: newClientSessions(id),
+		name:           name,
+		pending:        make(map[string]*call),
+	}
+}
+
+// start starts pipelining commands over connection with codec
+func (p *pipeline) start(conn net.Conn, codec pipelineCodec) {
+	p.Lock()
+	p.conn = conn
+	p.codec = codec
+	p.Unlock()
+	go p.recv()
+}
+
+// Close closes the connection and fails all pending commands
+func (p *pipeline) Close() error {
+	p.Lock()
+	conn := p.conn
+	p.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (p *pipeline) recv() {
+	for {
+		reply, err := p.codec.decode()
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		key := forwardKey(reply.Command)
+		p.Lock()
+		call, exists := p.pending[key]
+		delete(p.pending, key)
+		p.Unlock()
+		if !exists {
+			log.Errorf("%s received unknown or timed out reply %v", p.name, reply)
+			continue
+		}
+		if call.timer != nil {
+			call.timer.Stop()
+		}
+		p.release(call.session, reply.Err)
+		call.future.complete(reply.Value, reply.Err)
+	}
+}
+
+// fail completes every pending command with error
+func (p *pipeline) fail(err error) {
+	log.Debugf("%s closed: %v", p.name, err)
+	p.Lock()
+	defer p.Unlock()
+	p.err = ErrClosed
+	for key, call := range p.pending {
+		call.future.complete(nil, ErrClosed)
+		delete(p.pending, key)
+	}
+}
+
+// send pipelines command with key and value, value == nil is a read.
+// The command fails with ErrTimeout if its reply is not received within config.ClientTimeout
+func (p *pipeline) send(key Key, value Value) *Future {
+	f := newFuture()
+	s := p.acquire()
+	cmd := Command{
+		Key:       key,
+		Value:     value,
+		ClientID:  s.id,
+		CommandID: s.cid,
+	}
+	k := forwardKey(cmd)
+
+	p.Lock()
+	if p.err != nil || p.conn == nil {
+		p.Unlock()
+		p.release(s, ErrClosed)
+		f.complete(nil, ErrClosed)
+		return f
+	}
+	c := &call{future: f, session: s}
+	p.pending[k] = c
+	err := p.codec.encode(cmd)
+	if err != nil {
+		delete(p.pending, k)
+	} else if config.ClientTimeout > 0 {
+		c.timer = time.AfterFunc(time.Duration(config.ClientTimeout)*time.Millisecond, func() { p.timeout(k, c) })
+	}
+	p.Unlock()
+
+	if err != nil {
+		p.release(s, err)
+		f.complete(nil, err)
+	}
+	return f
+}
+
+// timeout fails call of key if it is still pending, its session is dropped
+// because the command may still be applied later
+func (p *pipeline) timeout(key string, c *call) {
+	p.Lock()
+	pending, exists := p.pending[key]
+	if !exists || pending != c {
+		p.Unlock()
+		return
+	}
+	delete(p.pending, key)
+	p.Unlock()
+	log.Debugf("%s command %d of session %s timeout", p.name, c.session.cid, c.session.id)
+	p.release(c.session, ErrTimeout)
+	c.future.complete(nil, ErrTimeout)
+}
+
+// GetAsync issues a read of key and returns its future
+func (p *pipeline) GetAsync(key Key) *Future {
+	return p.send(key, nil)
+}
+
+// PutAsync issues a write of key value pair and returns its future
+func (p *pipeline) PutAsync(key Key, value Value) *Future {
+	return p.send(key, value)
+}
+
+// Get implements Client interface by waiting on GetAsync
+func (p *pipeline) Get(key Key) (Value, error) {
+	return p.GetAsync(key).Get()
+}
+
+// Put implements Client interface by waiting on PutAsync
+func (p *pipeline) Put(key Key, value Value) error {
+	_, err := p.PutAsync(key, value).Get()
+	return err
+}
+
+// AsyncClient pipelines commands to one replica over a single persistent http stream connection
+// of json encoded commands, any number of commands can be outstanding and complete in any order.
+// AsyncClient is safe for concurrent use by multiple goroutines
+type AsyncClient struct {
+	ID   ID     // replica id this client connects to
+	Addr string // http address of the replica
+	pipeline
+}
+
+// NewAsyncClient creates a new AsyncClient to replica id from config
+func NewAsyncClient(id ID) *AsyncClient {
+	return &AsyncClient{
+		ID:       id,
+		Addr:     config.HTTPAddrs[id],
+		pipeline: newPipeline(id, "async client to "+string(id)),
+	}
+}
+
+// Connect opens the stream connection to the replica
+func (c *AsyncClient) Connect() error {
+	u, err := url.Parse(c.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Addr+"/stream", nil)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return errors.New(res.Status)
+	}
+
+	writer := bufio.NewWriter(conn)
+	c.start(conn, &streamCodec{
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		decoder: json.NewDecoder(reader),
+	})
+	return nil
+}
+
+// streamCodec encodes commands as json lines and decodes StreamReply of http stream connection
+type streamCodec struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+func (c *streamCodec) encode(cmd Command) error {
+	if err := c.encoder.Encode(cmd); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+func (c *streamCodec) decode() (Reply, error) {
+	var m StreamReply
+	if err := c.decoder.Decode(&m); err != nil {
+		return Reply{}, err
+	}
+	reply := Reply{
+		Command: Command{ClientID: m.ClientID, CommandID: m.CommandID},
+		Value:   m.Value,
+	}
+	if m.Err != "" {
+		reply.Err = errors.New(m.Err)
+	}
+	return reply, nil
+}
@@ -0,0 +1,124 @@
+package paxi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAsyncClient(t *testing.T) {
+	n := &node{
+		id:          "1.1",
+		MessageChan: make(chan interface{}, 100),
+	}
+	// replies every read with its key and every write with its value, out of order
+	go func() {
+		for m := range n.MessageChan {
+			r := m.(Request)
+			go func(r Request) {
+				v := r.Command.Value
+				if r.Command.IsRead() {
+					v = Value(strconv.Itoa(int(r.Command.Key)))
+				}
+				r.Reply(Reply{Command: r.Command, Value: v})
+			}(r)
+		}
+	}()
+	server := httptest.NewServer(http.HandlerFunc(n.handleStream))
+	defer server.Close()
+
+	c := NewAsyncClient("1.1")
+	c.Addr = server.URL
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	futures := make([]*Future, 1000)
+	for i := range futures {
+		if i%2 == 0 {
+			futures[i] = c.GetAsync(Key(i))
+		} else {
+			futures[i] = c.PutAsync(Key(i), Value(strconv.Itoa(-i)))
+		}
+	}
+	for i, f := range futures {
+		v, err := f.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strconv.Itoa(i)
+		if i%2 == 1 {
+			want = strconv.Itoa(-i)
+		}
+		if string(v) != want {
+			t.Errorf("future %d = %s, want %s", i, v, want)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.Get(Key(i))
+			if err != nil || string(v) != strconv.Itoa(i) {
+				t.Errorf("Get(%d) = %s, %v", i, v, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c.Close()
+	if _, err := c.Get(1); err == nil {
+		t.Error("Get on closed client succeeded")
+	}
+}
+
+func TestAsyncClientTimeout(t *testing.T) {
+	timeout := config.ClientTimeout
+	config.ClientTimeout = 50
+	defer func() { config.ClientTimeout = timeout }()
+
+	n := &node{
+		id:          "1.1",
+		MessageChan: make(chan interface{}, 100),
+	}
+	// reply of key 1 is lost
+	go func() {
+		for m := range n.MessageChan {
+			r := m.(Request)
+			if r.Command.Key != 1 {
+				r.Reply(Reply{Command: r.Command, Value: r.Command.Value})
+			}
+		}
+	}()
+	server := httptest.NewServer(http.HandlerFunc(n.handleStream))
+	defer server.Close()
+
+	c := NewAsyncClient("1.1")
+	c.Addr = server.URL
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Put(1, Value("a")); err != ErrTimeout {
+		t.Fatalf("Put of lost reply returns %v, want %v", err, ErrTimeout)
+	}
+	c.Lock()
+	pending := len(c.pending)
+	c.Unlock()
+	if pending != 0 || len(c.idle) != 0 {
+		t.Errorf("%d pending commands and %d idle sessions after timeout, want the session dropped", pending, len(c.idle))
+	}
+
+	if err := c.Put(2, Value("b")); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.idle) != 1 || c.idle[0].cid != 1 {
+		t.Errorf("idle sessions %v, want a new session for the command after timeout", c.idle)
+	}
+}
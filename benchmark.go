@@ -3,6 +3,7 @@ package paxi
 import (
 	"math"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,16 +18,26 @@ type DB interface {
 	Stop() error
 }
 
+// ReplicaDB is optionally implemented by DB to report the replica that served each operation
+type ReplicaDB interface {
+	ReadFrom(key int) (int, ID, error)
+	WriteTo(key, value int) (ID, error)
+}
+
 // Bconfig holds all benchmark configuration
 type Bconfig struct {
 	T                    int     // total number of running time in seconds
 	N                    int     // total number of requests
 	K                    int     // key sapce
 	W                    float64 // write ratio
-	Throttle             int     // requests per second throttle, unused if 0
+	Throttle             int     // requests per second throttle, or arrival rate of open-loop load, unused if 0
+	Arrival              string  // arrival schedule of open-loop load, "constant" or "poisson", closed-loop if empty
 	Concurrency          int     // number of simulated clients
 	Distribution         string  // distribution
 	LinearizabilityCheck bool    // run linearizability checker at the end of benchmark
+	OnlineCheck          bool    // check linearizability of operations as they complete, history is still written
+	Interval             int     // interval of throughput and latency time series in milliseconds, disabled if 0
+	HistoryFormat        string  // operation history file format, "csv" or "jsonl"
 	// rounds       int    // repeat in many rounds sequentially
 
 	// conflict distribution
@@ -58,6 +69,8 @@ func DefaultBConfig() Bconfig {
 		Concurrency:          1,
 		Distribution:         "uniform",
 		LinearizabilityCheck: true,
+		HistoryFormat:        HistoryCSV,
+		Interval:             0,
 		Conflicts:            100,
 		Min:                  0,
 		Mu:                   0,
@@ -76,8 +89,13 @@ type Benchmark struct {
 	Bconfig
 	*History
 
+	ID ID // client id, prefix of the session id of every worker in history
+
 	rate      *Limiter
-	latency   []time.Duration // latency per operation
+	arrivals  *Arrivals
+	online    *OnlineChecker
+	series    *Series
+	latency   *Histogram // latency of operations
 	startTime time.Time
 	zipf      *rand.Zipf
 	counter   int
@@ -91,8 +109,25 @@ func NewBenchmark(db DB) *Benchmark {
 	b.db = db
 	b.Bconfig = config.Benchmark
 	b.History = NewHistory()
-	if b.Throttle > 0 {
-		b.rate = NewLimiter(b.Throttle)
+	b.latency = NewLatencyHistogram()
+	switch b.Arrival {
+	case "":
+		if b.Throttle > 0 {
+			b.rate = NewLimiter(b.Throttle)
+		}
+	case ArrivalConstant, ArrivalPoisson:
+		if b.Throttle <= 0 {
+			log.Fatalf("open-loop %s arrival requires throttle rate", b.Arrival)
+		}
+		b.arrivals = NewArrivals(b.Throttle, b.Arrival)
+	default:
+		log.Fatalf("unknown arrival schedule %s", b.Arrival)
+	}
+	if b.OnlineCheck {
+		b.online = NewOnlineChecker()
+	}
+	if b.Interval > 0 {
+		b.series = NewSeries(time.Duration(b.Interval) * time.Millisecond)
 	}
 	rand.Seed(time.Now().UTC().UnixNano())
 	r := rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
@@ -106,28 +141,28 @@ func (b *Benchmark) Load() {
 	b.Throttle = 0
 
 	b.db.Init()
-	keys := make(chan int, b.Concurrency)
+	keys := make(chan request, b.Concurrency)
 	latencies := make(chan time.Duration, 10000)
 	defer close(latencies)
 	go b.collect(latencies)
 
 	b.startTime = time.Now()
 	for i := 0; i < b.Concurrency; i++ {
-		go b.worker(keys, latencies)
+		go b.worker(i, keys, latencies)
 	}
 	for i := b.Min; i < b.Min+b.K; i++ {
 		b.wait.Add(1)
-		keys <- i
+		keys <- request{key: i}
 	}
+	close(keys)
+	b.wait.Wait()
 	t := time.Now().Sub(b.startTime)
 
 	b.db.Stop()
-	close(keys)
-	b.wait.Wait()
-	stat := Statistic(b.latency)
+	stat := b.latency.Stat()
 
 	log.Infof("Benchmark took %v\n", t)
-	log.Infof("Throughput %f\n", float64(len(b.latency))/t.Seconds())
+	log.Infof("Throughput %f\n", float64(stat.Size)/t.Seconds())
 	log.Info(stat)
 }
 
@@ -140,18 +175,23 @@ func (b *Benchmark) Run(path string) {
 		defer close(stop)
 	}
 
-	b.latency = make([]time.Duration, 0)
-	keys := make(chan int, b.Concurrency)
+	b.latency = NewLatencyHistogram()
+	keys := make(chan request, b.Concurrency)
 	latencies := make(chan time.Duration, 1000000)
 	defer close(latencies)
 	go b.collect(latencies)
 
 	for i := 0; i < b.Concurrency; i++ {
-		go b.worker(keys, latencies)
+		go b.worker(i, keys, latencies)
 	}
 
 	b.db.Init()
 	b.startTime = time.Now()
+	if b.series != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go b.live(done)
+	}
 	if b.T > 0 {
 		timer := time.NewTimer(time.Second * time.Duration(b.T))
 	loop:
@@ -161,13 +201,13 @@ func (b *Benchmark) Run(path string) {
 				break loop
 			default:
 				b.wait.Add(1)
-				keys <- b.next()
+				keys <- b.request()
 			}
 		}
 	} else {
 		for i := 0; i < b.N; i++ {
 			b.wait.Add(1)
-			keys <- b.next()
+			keys <- b.request()
 		}
 		b.wait.Wait()
 	}
@@ -175,25 +215,53 @@ func (b *Benchmark) Run(path string) {
 
 	b.db.Stop()
 	close(keys)
-	stat := Statistic(b.latency)
+	stat := b.latency.Stat()
 	log.Infof("Concurrency = %d", b.Concurrency)
+	if b.arrivals != nil {
+		log.Infof("Open-loop %s arrival rate = %d", b.Arrival, b.Throttle)
+	}
 	log.Infof("Write Ratio = %f", b.W)
 	log.Infof("Number of Keys = %d", b.K)
 	log.Infof("Benchmark Time = %v\n", t)
-	log.Infof("Throughput = %f\n", float64(len(b.latency))/t.Seconds())
+	log.Infof("Throughput = %f\n", float64(stat.Size)/t.Seconds())
 	log.Info(stat)
 
-	stat.WriteFile("latency")
-	b.History.WriteFile(path)
+	if err := b.latency.WriteFile("latency"); err != nil {
+		log.Error(err)
+	}
+
+	if b.series != nil {
+		for _, ext := range []string{".series.csv", ".series.json"} {
+			if err := b.series.WriteFile(path + ext); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	if b.online != nil {
+		log.Infof("Online checker found %d anomalies", b.online.Anomalies())
+	}
+
+	file := path
+	if b.HistoryFormat != "" {
+		file += "." + b.HistoryFormat
+	}
+	if err := b.History.WriteFile(file); err != nil {
+		log.Error(err)
+	}
 
 	if b.LinearizabilityCheck {
-		n := b.History.Linearizable()
+		n, err := b.History.WriteReport(path)
+		if err != nil {
+			log.Error(err)
+		}
 		if n == 0 {
 			log.Info("The execution is linearizable.")
 		} else {
 			log.Info("The execution is NOT linearizable.")
 			log.Infof("Total anomaly read operations are %d", n)
 			log.Infof("Anomaly percentage is %f", float64(n)/float64(stat.Size))
+			log.Infof("Anomalies are reported in %s.anomalies.txt and %s.timeline.html", path, path)
 		}
 	}
 }
@@ -236,48 +304,112 @@ func (b *Benchmark) next() int {
 		log.Fatalf("unknown distribution %s", b.Distribution)
 	}
 
-	if b.Throttle > 0 {
+	if b.rate != nil && b.Throttle > 0 {
 		b.rate.Wait()
 	}
 
 	return key
 }
 
-func (b *Benchmark) worker(keys <-chan int, result chan<- time.Duration) {
+// request is a key to operate on and the time it is intended to be sent in open-loop load, zero in closed-loop
+type request struct {
+	key  int
+	time time.Time
+}
+
+// request generates next request, waiting for its arrival time in open-loop load
+func (b *Benchmark) request() request {
+	r := request{key: b.next()}
+	if b.arrivals != nil && b.Throttle > 0 {
+		r.time = b.arrivals.Wait()
+	}
+	return r
+}
+
+// worker issues operations of one client session sequentially
+// in open-loop load, latency is measured from intended send time so the time a request waits for a free worker is included
+func (b *Benchmark) worker(session int, keys <-chan request, result chan<- time.Duration) {
 	var s time.Time
 	var e time.Time
 	var v int
 	var err error
-	for k := range keys {
-		op := new(operation)
+	rdb, _ := b.db.(ReplicaDB)
+	client := ID(string(b.ID) + "-" + strconv.Itoa(session))
+	cid := 0
+	for r := range keys {
+		k := r.key
+		cid++
+		op := &operation{OpRecord: OpRecord{Client: client, Command: cid}, key: k}
+		if b.online != nil {
+			b.online.Begin(op, time.Since(b.startTime).Nanoseconds())
+		}
 		if rand.Float64() < b.W {
 			v = rand.Int()
 			s = time.Now()
-			err = b.db.Write(k, v)
+			if rdb != nil {
+				op.Replica, err = rdb.WriteTo(k, v)
+			} else {
+				err = b.db.Write(k, v)
+			}
 			e = time.Now()
 			op.input = v
+			op.Type = WriteOp
 		} else {
 			s = time.Now()
-			v, err = b.db.Read(k)
+			if rdb != nil {
+				v, op.Replica, err = rdb.ReadFrom(k)
+			} else {
+				v, err = b.db.Read(k)
+			}
 			e = time.Now()
-			op.output = v
+			if err == nil {
+				op.output = v
+			}
+		}
+		latency := e.Sub(s)
+		if !r.time.IsZero() {
+			latency = e.Sub(r.time)
+		}
+		if b.series != nil {
+			b.series.Add(e.Sub(b.startTime), op.Type, latency, err)
 		}
 		op.start = s.Sub(b.startTime).Nanoseconds()
 		if err == nil {
 			op.end = e.Sub(b.startTime).Nanoseconds()
-			result <- e.Sub(s)
+			result <- latency
 		} else {
 			op.end = math.MaxInt64
+			op.Err = err.Error()
 			log.Error(err)
+			// failed operations are not collected, but still complete
+			b.wait.Done()
+		}
+		if b.online != nil {
+			b.online.Add(op)
 		}
-		op.key = k
 		b.History.AddOperation(k, op)
 	}
 }
 
+// live logs throughput and latency of every interval once it completes
+func (b *Benchmark) live(done <-chan struct{}) {
+	ticker := time.NewTicker(b.series.interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, interval := range b.series.Interval(i) {
+				log.Info(interval)
+			}
+		}
+	}
+}
+
 func (b *Benchmark) collect(latencies <-chan time.Duration) {
 	for t := range latencies {
-		b.latency = append(b.latency, t)
+		b.latency.Record(t)
 		b.wait.Done()
 	}
 }
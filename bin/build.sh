@@ -2,3 +2,4 @@
 go build ../server/
 go build ../client/
 go build ../cmd/
+go build ../stat/
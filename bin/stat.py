@@ -1,13 +1,8 @@
 
+import os
+import subprocess
 import sys
-import numpy as np
-
-data = np.loadtxt(sys.argv[1])
-print(np.average(data))
-print(np.median(data))
-print(np.min(data))
-print(np.max(data))
-print(np.percentile(data, 95))
-print(np.percentile(data, 99))
-print(np.percentile(data, 99.9))
 
+# latency is written as histogram by benchmark, statistics are computed by the stat tool built next to this script
+stat = os.path.join(os.path.dirname(os.path.abspath(__file__)), "stat")
+sys.exit(subprocess.call([stat] + sys.argv[1:]))
@@ -0,0 +1,153 @@
+package paxi
+
+import (
+	"encoding/gob"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ailidani/paxi/log"
+)
+
+// serveTCP accepts client connections speaking the same gob framed message protocol as replicas,
+// each connection sends Request messages and receives Reply messages in completion order
+func (n *node) serveTCP(addr string) {
+	if !strings.Contains(addr, "://") {
+		addr = "tcp://" + addr
+	}
+	uri, err := url.Parse(addr)
+	if err != nil {
+		log.Fatal("tcp url parse error: ", err)
+	}
+	listener, err := net.Listen("tcp", ":"+uri.Port())
+	if err != nil {
+		log.Fatal("TCP Listener error: ", err)
+	}
+	log.Info("tcp client server starting on ", uri.Port())
+	go func(listener net.Listener) {
+		defer listener.Close()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					log.Error("TCP Accept error: ", err)
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+				// listener is closed
+				log.Info("tcp client server stopped: ", err)
+				return
+			}
+			go n.handleTCP(conn)
+		}
+	}(listener)
+}
+
+func (n *node) handleTCP(conn net.Conn) {
+	defer conn.Close()
+	var lock sync.Mutex
+	encoder := gob.NewEncoder(conn)
+	decoder := gob.NewDecoder(conn)
+	for {
+		var m interface{}
+		if err := decoder.Decode(&m); err != nil {
+			if err != io.EOF {
+				log.Error(err)
+			}
+			return
+		}
+		req, ok := m.(Request)
+		if !ok {
+			log.Errorf("node %v received non request %v from client", n.id, m)
+			continue
+		}
+		req.Timestamp = time.Now().UnixNano()
+		req.NodeID = n.id
+		req.c = make(chan Reply, 1)
+		n.MessageChan <- req
+		go func(req Request) {
+			var m interface{}
+			reply := <-req.c
+			if reply.Err != nil {
+				reply.Err = ReplyError(reply.Err.Error())
+			}
+			m = reply
+			lock.Lock()
+			defer lock.Unlock()
+			if err := encoder.Encode(&m); err != nil {
+				log.Error(err)
+			}
+		}(req)
+	}
+}
+
+// BinaryClient implements Client interface over the binary tcp protocol of one replica,
+// concurrent commands are pipelined over a single connection.
+// BinaryClient is safe for concurrent use by multiple goroutines
+type BinaryClient struct {
+	ID   ID     // replica id this client connects to
+	Addr string // tcp address of the replica
+	pipeline
+}
+
+// NewBinaryClient creates a new BinaryClient to replica id from config
+func NewBinaryClient(id ID) *BinaryClient {
+	return &BinaryClient{
+		ID:       id,
+		Addr:     config.TCPAddrs[id],
+		pipeline: newPipeline(id, "binary client to "+string(id)),
+	}
+}
+
+// Connect dials the replica
+func (c *BinaryClient) Connect() error {
+	addr := c.Addr
+	if strings.Contains(addr, "://") {
+		uri, err := url.Parse(addr)
+		if err != nil {
+			return err
+		}
+		addr = uri.Host
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	c.start(conn, &gobCodec{
+		encoder: gob.NewEncoder(conn),
+		decoder: gob.NewDecoder(conn),
+	})
+	return nil
+}
+
+// gobCodec encodes commands as Request and decodes Reply messages of the binary tcp protocol
+type gobCodec struct {
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+}
+
+func (c *gobCodec) encode(cmd Command) error {
+	var m interface{} = Request{
+		Command:    cmd,
+		Properties: make(map[string]string),
+	}
+	return c.encoder.Encode(&m)
+}
+
+func (c *gobCodec) decode() (Reply, error) {
+	for {
+		var m interface{}
+		if err := c.decoder.Decode(&m); err != nil {
+			return Reply{}, err
+		}
+		reply, ok := m.(Reply)
+		if !ok {
+			log.Errorf("binary client received non reply %v", m)
+			continue
+		}
+		return reply, nil
+	}
+}
@@ -0,0 +1,69 @@
+package paxi
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestBinaryClient(t *testing.T) {
+	n := &node{
+		id:          "1.1",
+		MessageChan: make(chan interface{}, 100),
+	}
+	// replies every read with its key and fails writes to key 0
+	go func() {
+		for m := range n.MessageChan {
+			r := m.(Request)
+			go func(r Request) {
+				reply := Reply{Command: r.Command}
+				if r.Command.IsRead() {
+					reply.Value = Value(strconv.Itoa(int(r.Command.Key)))
+				} else if r.Command.Key == 0 {
+					reply.Err = errors.New("rejected")
+				}
+				r.Reply(reply)
+			}(r)
+		}
+	}()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go n.handleTCP(conn)
+		}
+	}()
+
+	c := NewBinaryClient("1.1")
+	c.Addr = listener.Addr().String()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	futures := make([]*Future, 100)
+	for i := range futures {
+		futures[i] = c.GetAsync(Key(i))
+	}
+	for i, f := range futures {
+		v, err := f.Get()
+		if err != nil || string(v) != strconv.Itoa(i) {
+			t.Errorf("Get(%d) = %s, %v", i, v, err)
+		}
+	}
+
+	if err := c.Put(1, Value("a")); err != nil {
+		t.Error(err)
+	}
+	if err := c.Put(0, Value("a")); err == nil || err.Error() != "rejected" {
+		t.Errorf("Put(0) error = %v, want rejected", err)
+	}
+}
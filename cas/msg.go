@@ -0,0 +1,83 @@
+package cas
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+)
+
+func init() {
+	gob.Register(Get{})
+	gob.Register(GetReply{})
+	gob.Register(PreSet{})
+	gob.Register(PreSetReply{})
+	gob.Register(Set{})
+	gob.Register(SetReply{})
+}
+
+// Fragment is one Reed-Solomon coded element of a value
+type Fragment struct {
+	Index int // shard index of the server storing fragment
+	Size  int // size of the whole value
+	Data  []byte
+}
+
+// Get message queries the highest finalized tag
+type Get struct {
+	ID  paxi.ID
+	CID int
+	Key paxi.Key
+}
+
+// GetReply message returns highest finalized tag and whether its fragment is still available
+type GetReply struct {
+	ID        paxi.ID
+	CID       int
+	Key       paxi.Key
+	Tag       paxi.Ballot
+	Available bool
+}
+
+func (m GetReply) String() string {
+	return fmt.Sprintf("GetReply {id=%s cid=%d key=%v tag=%v available=%t}", m.ID, m.CID, m.Key, m.Tag, m.Available)
+}
+
+// PreSet message stores coded fragment of a new version in pre-written state
+type PreSet struct {
+	ID       paxi.ID
+	CID      int
+	Key      paxi.Key
+	Tag      paxi.Ballot
+	Fragment Fragment
+}
+
+// PreSetReply acknowledges a pre-written fragment
+type PreSetReply struct {
+	ID  paxi.ID
+	CID int
+	Key paxi.Key
+}
+
+// Set message finalizes a version, a reader also asks for the fragment of version
+type Set struct {
+	ID   paxi.ID
+	CID  int
+	Key  paxi.Key
+	Tag  paxi.Ballot
+	Read bool
+}
+
+// SetReply acknowledges a finalized version with its fragment to reader if available
+type SetReply struct {
+	ID        paxi.ID
+	CID       int
+	Key       paxi.Key
+	Tag       paxi.Ballot
+	Available bool
+	Fragment  Fragment
+}
+
+func (m SetReply) String() string {
+	return fmt.Sprintf("SetReply {id=%s cid=%d key=%v tag=%v available=%t}", m.ID, m.CID, m.Key, m.Tag, m.Available)
+}
@@ -0,0 +1,355 @@
+package cas
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+var delta = flag.Int("delta", 5, "number of older finalized versions each server keeps fragments of")
+
+type state int
+
+// states of each instance
+const (
+	GetPhase state = iota
+	PreSetPhase
+	SetPhase
+	Done
+)
+
+type entry struct {
+	r         *paxi.Request
+	state     state
+	quorum    *paxi.Quorum
+	tag       paxi.Ballot
+	fragments [][]byte // fragments of tag received by reader
+	present   int      // number of fragments received
+	size      int
+}
+
+// version is the coded fragment of a value stored by a server
+type version struct {
+	fragment *Fragment // nil if never received or garbage collected
+	fin      bool      // finalized
+}
+
+// Replica implements coded atomic storage (CAS) with garbage collection of old versions (CASGC).
+// Every server stores only one Reed-Solomon fragment of each version, the i-th server in sorted order of ids
+// stores the i-th fragment. Writes proceed in Get, PreSet and Set phase, reads proceed in Get and Set phase
+// and decode value from fragments. Any two quorums intersect in enough servers to decode a finalized version.
+type Replica struct {
+	paxi.Node
+	cid   int
+	ids   []paxi.ID // servers sorted by id
+	index int       // fragment index of this server
+	x     int       // number of data fragments
+	k     int       // number of parity fragments
+
+	log   map[int]*entry
+	store map[paxi.Key]map[paxi.Ballot]*version
+	tag   map[paxi.Key]paxi.Ballot // highest finalized tag
+	floor map[paxi.Key]paxi.Ballot // fragments of lower tags are garbage collected
+	last  map[paxi.Key]paxi.Ballot // highest tag written by this replica
+}
+
+// NewReplica generates CAS replica, fragment layout is given by erasure_data and erasure_parity in config
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates CAS replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.ids = paxi.GetConfig().IDs()
+	sort.Sort(paxi.IDs(r.ids))
+	r.index = -1
+	for i, id := range r.ids {
+		if id == r.ID() {
+			r.index = i
+		}
+	}
+	r.x = paxi.GetConfig().ErasureData
+	r.k = paxi.GetConfig().ErasureParity
+	if r.x <= 0 || r.x+r.k != len(r.ids) {
+		log.Fatalf("erasure code of %d data and %d parity fragments does not match %d servers", r.x, r.k, len(r.ids))
+	}
+	r.log = make(map[int]*entry)
+	r.store = make(map[paxi.Key]map[paxi.Ballot]*version)
+	r.tag = make(map[paxi.Key]paxi.Ballot)
+	r.floor = make(map[paxi.Key]paxi.Ballot)
+	r.last = make(map[paxi.Key]paxi.Ballot)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(Get{}, r.handleGet)
+	r.Register(GetReply{}, r.handleGetReply)
+	r.Register(PreSet{}, r.handlePreSet)
+	r.Register(PreSetReply{}, r.handlePreSetReply)
+	r.Register(Set{}, r.handleSet)
+	r.Register(SetReply{}, r.handleSetReply)
+	return r
+}
+
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Node %s received Request %v", r.ID(), m)
+	r.cid++
+	r.log[r.cid] = &entry{
+		r:      &m,
+		state:  GetPhase,
+		quorum: paxi.NewQuorum(),
+	}
+	r.query(r.cid, m.Command.Key)
+}
+
+// query starts Get phase of instance cid
+func (r *Replica) query(cid int, k paxi.Key) {
+	m := Get{
+		ID:  r.ID(),
+		CID: cid,
+		Key: k,
+	}
+	r.Broadcast(m)
+	r.handleGetReply(r.get(m))
+}
+
+func (r *Replica) get(m Get) GetReply {
+	t := r.tag[m.Key]
+	v := r.store[m.Key][t]
+	return GetReply{
+		ID:        r.ID(),
+		CID:       m.CID,
+		Key:       m.Key,
+		Tag:       t,
+		Available: v != nil && v.fragment != nil,
+	}
+}
+
+func (r *Replica) handleGet(m Get) {
+	r.Send(m.ID, r.get(m))
+}
+
+func (r *Replica) preset(m PreSet) PreSetReply {
+	if m.Tag >= r.floor[m.Key] {
+		if r.store[m.Key] == nil {
+			r.store[m.Key] = make(map[paxi.Ballot]*version)
+		}
+		v, exists := r.store[m.Key][m.Tag]
+		if !exists {
+			v = new(version)
+			r.store[m.Key][m.Tag] = v
+		}
+		if v.fragment == nil {
+			f := m.Fragment
+			v.fragment = &f
+		}
+	}
+	return PreSetReply{
+		ID:  r.ID(),
+		CID: m.CID,
+		Key: m.Key,
+	}
+}
+
+func (r *Replica) handlePreSet(m PreSet) {
+	r.Send(m.ID, r.preset(m))
+}
+
+func (r *Replica) set(m Set) SetReply {
+	if r.store[m.Key] == nil {
+		r.store[m.Key] = make(map[paxi.Ballot]*version)
+	}
+	v, exists := r.store[m.Key][m.Tag]
+	if !exists {
+		v = new(version)
+		if m.Tag >= r.floor[m.Key] {
+			r.store[m.Key][m.Tag] = v
+		}
+	}
+	v.fin = true
+	if m.Tag > r.tag[m.Key] {
+		r.tag[m.Key] = m.Tag
+		r.gc(m.Key)
+	}
+	reply := SetReply{
+		ID:  r.ID(),
+		CID: m.CID,
+		Key: m.Key,
+		Tag: m.Tag,
+	}
+	if v, exists := r.store[m.Key][m.Tag]; exists && v.fragment != nil {
+		reply.Available = true
+		if m.Read {
+			reply.Fragment = *v.fragment
+		}
+	}
+	return reply
+}
+
+func (r *Replica) handleSet(m Set) {
+	r.Send(m.ID, r.set(m))
+}
+
+// gc keeps fragments of the highest delta+1 finalized versions of key and newer pre-written versions
+func (r *Replica) gc(k paxi.Key) {
+	fin := make([]paxi.Ballot, 0, len(r.store[k]))
+	for t, v := range r.store[k] {
+		if v.fin {
+			fin = append(fin, t)
+		}
+	}
+	if len(fin) <= *delta+1 {
+		return
+	}
+	sort.Slice(fin, func(i, j int) bool { return fin[i] > fin[j] })
+	r.floor[k] = fin[*delta]
+	for t := range r.store[k] {
+		if t < r.floor[k] {
+			delete(r.store[k], t)
+		}
+	}
+}
+
+func (r *Replica) handleGetReply(m GetReply) {
+	e, exists := r.log[m.CID]
+	if !exists || e.state != GetPhase {
+		return
+	}
+	if m.Tag > e.tag {
+		e.tag = m.Tag
+	}
+	e.quorum.ACK(m.ID)
+	if !e.quorum.MajorityX() {
+		return
+	}
+
+	e.quorum = paxi.NewQuorum()
+	if e.r.Command.IsRead() {
+		// key is never written
+		if e.tag == 0 {
+			r.reply(m.CID, nil)
+			return
+		}
+		e.state = SetPhase
+		e.fragments = make([][]byte, r.x+r.k)
+		e.present = 0
+		s := Set{
+			ID:   r.ID(),
+			CID:  m.CID,
+			Key:  m.Key,
+			Tag:  e.tag,
+			Read: true,
+		}
+		r.Broadcast(s)
+		r.handleSetReply(r.set(s))
+		return
+	}
+
+	// new tag is higher than any finalized and any tag written by this replica before
+	if r.last[m.Key] > e.tag {
+		e.tag = r.last[m.Key]
+	}
+	e.tag.Next(r.ID())
+	r.last[m.Key] = e.tag
+	shards, err := paxi.Splitvalue(e.r.Command.Value, r.x, r.k)
+	if err != nil {
+		log.Error(err)
+		e.r.Reply(paxi.Reply{
+			Command: e.r.Command,
+			Err:     err,
+		})
+		delete(r.log, m.CID)
+		return
+	}
+	e.state = PreSetPhase
+	var local PreSet
+	for i, id := range r.ids {
+		p := PreSet{
+			ID:       r.ID(),
+			CID:      m.CID,
+			Key:      m.Key,
+			Tag:      e.tag,
+			Fragment: Fragment{Index: i, Size: len(e.r.Command.Value), Data: shards[i]},
+		}
+		if id == r.ID() {
+			local = p
+			continue
+		}
+		r.Send(id, p)
+	}
+	r.handlePreSetReply(r.preset(local))
+}
+
+func (r *Replica) handlePreSetReply(m PreSetReply) {
+	e, exists := r.log[m.CID]
+	if !exists || e.state != PreSetPhase {
+		return
+	}
+	e.quorum.ACK(m.ID)
+	if e.quorum.MajorityX() {
+		e.state = SetPhase
+		e.quorum = paxi.NewQuorum()
+		s := Set{
+			ID:  r.ID(),
+			CID: m.CID,
+			Key: m.Key,
+			Tag: e.tag,
+		}
+		r.Broadcast(s)
+		r.handleSetReply(r.set(s))
+	}
+}
+
+func (r *Replica) handleSetReply(m SetReply) {
+	e, exists := r.log[m.CID]
+	if !exists || e.state != SetPhase || m.Tag != e.tag {
+		return
+	}
+	e.quorum.ACK(m.ID)
+	if e.r.Command.IsWrite() {
+		if e.quorum.MajorityX() {
+			r.reply(m.CID, nil)
+		}
+		return
+	}
+
+	f := m.Fragment
+	if m.Available && f.Index >= 0 && f.Index < len(e.fragments) && e.fragments[f.Index] == nil {
+		e.fragments[f.Index] = f.Data
+		e.size = f.Size
+		e.present++
+	}
+	if e.quorum.MajorityX() && e.present >= r.x {
+		v, err := paxi.Recovervalue(e.fragments, r.x, r.k, e.size)
+		if err != nil {
+			log.Error(err)
+			e.r.Reply(paxi.Reply{
+				Command: e.r.Command,
+				Err:     err,
+			})
+			delete(r.log, m.CID)
+			return
+		}
+		r.reply(m.CID, v)
+		return
+	}
+	// fragments of tag are garbage collected by newer writes, read again
+	if e.quorum.Size == len(r.ids) {
+		e.state = GetPhase
+		e.tag = 0
+		e.quorum = paxi.NewQuorum()
+		r.query(m.CID, m.Key)
+	}
+}
+
+// reply completes instance cid
+func (r *Replica) reply(cid int, v paxi.Value) {
+	e := r.log[cid]
+	e.state = Done
+	e.r.Reply(paxi.Reply{
+		Command: e.r.Command,
+		Value:   v,
+	})
+	delete(r.log, cid)
+}
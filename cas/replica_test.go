@@ -0,0 +1,108 @@
+package cas
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+// five servers for the default erasure code of 3 data and 2 parity fragments
+var ids = []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}
+
+func cluster(t *testing.T) map[paxi.ID]*paxitest.Node {
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		newReplica(nodes[id]).Run()
+	}
+	return nodes
+}
+
+// node stubs paxi.Node for server side handlers
+type node struct {
+	paxi.Node
+	id paxi.ID
+}
+
+func (n node) ID() paxi.ID { return n.id }
+
+func TestGarbageCollect(t *testing.T) {
+	r := &Replica{
+		Node:  node{id: "1.1"},
+		store: make(map[paxi.Key]map[paxi.Ballot]*version),
+		tag:   make(map[paxi.Key]paxi.Ballot),
+		floor: make(map[paxi.Key]paxi.Ballot),
+	}
+	var tag paxi.Ballot
+	for i := 0; i < *delta+5; i++ {
+		tag.Next("1.1")
+		r.preset(PreSet{ID: "1.1", Key: 1, Tag: tag, Fragment: Fragment{Data: []byte{byte(i)}}})
+		if reply := r.set(Set{ID: "1.1", Key: 1, Tag: tag, Read: true}); !reply.Available || reply.Fragment.Data[0] != byte(i) {
+			t.Errorf("set(%v) = %v, want fragment %d", tag, reply, i)
+		}
+	}
+	if len(r.store[1]) != *delta+1 {
+		t.Errorf("%d versions kept, want %d", len(r.store[1]), *delta+1)
+	}
+	if reply := r.get(Get{Key: 1}); reply.Tag != tag || !reply.Available {
+		t.Errorf("get() = %v, want available tag %v", reply, tag)
+	}
+
+	// pre-written fragment older than garbage collected versions is not kept
+	r.preset(PreSet{ID: "1.2", Key: 1, Tag: paxi.NewBallot(1, "1.2"), Fragment: Fragment{Data: []byte{0}}})
+	if reply := r.set(Set{ID: "1.2", Key: 1, Tag: paxi.NewBallot(1, "1.2"), Read: true}); reply.Available {
+		t.Errorf("set() of collected version = %v, want unavailable", reply)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	nodes := cluster(t)
+	request := func(id paxi.ID, c paxi.Command) paxi.Value {
+		return nodes[id].Call(t, c).Value
+	}
+	value := func(i int) paxi.Value {
+		return bytes.Repeat([]byte(strconv.Itoa(i)), 100+i)
+	}
+
+	if v := request("1.1", paxi.Command{Key: 1}); v != nil {
+		t.Errorf("read of key never written = %q", v)
+	}
+	for i := 1; i <= 5; i++ {
+		request(ids[i%len(ids)], paxi.Command{Key: 1, Value: value(i)})
+		for _, id := range ids {
+			if v := request(id, paxi.Command{Key: 1}); !bytes.Equal(v, value(i)) {
+				t.Errorf("read from %s = %q, expected %q", id, v, value(i))
+			}
+		}
+	}
+
+	// servers store only their own fragment
+	for _, id := range ids {
+		if f := nodes[id].Get(1); f != nil {
+			t.Errorf("server %s keeps value %q in database", id, f)
+		}
+	}
+
+	// server of a data fragment fails, values are decoded with parity fragments
+	nodes["1.2"].Fail(true)
+	live := []paxi.ID{"1.1", "1.3", "1.4", "1.5"}
+	for i := 6; i <= 10; i++ {
+		request(live[i%len(live)], paxi.Command{Key: 1, Value: value(i)})
+		for _, id := range live {
+			if v := request(id, paxi.Command{Key: 1}); !bytes.Equal(v, value(i)) {
+				t.Errorf("read from %s with failed server = %q, expected %q", id, v, value(i))
+			}
+		}
+	}
+}
+
+func TestLinearizable(t *testing.T) {
+	// linearizable with one failed server
+	nodes := cluster(t)
+	nodes["1.3"].Fail(true)
+	paxitest.Linearizable(t, []*paxitest.Node{nodes["1.1"], nodes["1.2"], nodes["1.4"], nodes["1.5"]}, 8, 50)
+}
@@ -0,0 +1,278 @@
+package chain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// Chain instance of chain replication, replicas are ordered from head to tail.
+// Head orders writes and propagates them down the chain, tail commits writes and serves reads.
+// The chain is reconfigured only by one configuration master with increasing versions,
+// and messages of older versions than the installed one are ignored,
+// so that replicas partitioned from each other never form chains of their own.
+// The configuration master is a separate replica outside of the chain, so any replica of the chain may fail,
+// and the chain keeps serving without reconfiguration if the master fails.
+type Chain struct {
+	paxi.Node
+
+	master  paxi.ID   // configuration master
+	version int       // configuration version
+	chain   []paxi.ID // replicas from head to tail
+
+	applied  int                   // highest sequence number applied
+	acked    int                   // highest sequence number committed by tail
+	updates  map[int]Update        // updates received but not acked yet
+	requests map[int]*paxi.Request // pending write requests of head
+
+	alive map[paxi.ID]time.Time // last heartbeat time of each replica
+}
+
+// configuration returns the configuration master given by flag or the first replica in sorted order of ids,
+// and the initial chain of the other replicas in sorted order
+func configuration() (paxi.ID, []paxi.ID) {
+	ids := paxi.GetConfig().IDs()
+	sort.Sort(paxi.IDs(ids))
+	m := paxi.ID(*master)
+	if m == "" {
+		m = ids[0]
+	}
+	chain := make([]paxi.ID, 0, len(ids))
+	for _, id := range ids {
+		if id != m {
+			chain = append(chain, id)
+		}
+	}
+	if len(chain) == 0 {
+		log.Fatalf("chain of %v without configuration master %s is empty", ids, m)
+	}
+	return m, chain
+}
+
+// NewChain creates new chain instance with initial configuration
+func NewChain(n paxi.Node) *Chain {
+	m, chain := configuration()
+	c := &Chain{
+		Node:     n,
+		master:   m,
+		chain:    chain,
+		updates:  make(map[int]Update, paxi.GetConfig().BufferSize),
+		requests: make(map[int]*paxi.Request, paxi.GetConfig().BufferSize),
+		alive:    make(map[paxi.ID]time.Time),
+	}
+	// every replica is alive until timeout
+	for _, id := range chain {
+		c.alive[id] = time.Now()
+	}
+	return c
+}
+
+// Master returns the configuration master
+func (c *Chain) Master() paxi.ID {
+	return c.master
+}
+
+// Head returns the head of current chain
+func (c *Chain) Head() paxi.ID {
+	return c.chain[0]
+}
+
+// Tail returns the tail of current chain
+func (c *Chain) Tail() paxi.ID {
+	return c.chain[len(c.chain)-1]
+}
+
+// IsHead indicates if this node is head of current chain
+func (c *Chain) IsHead() bool {
+	return c.Head() == c.ID()
+}
+
+// IsTail indicates if this node is tail of current chain
+func (c *Chain) IsTail() bool {
+	return c.Tail() == c.ID()
+}
+
+// Version returns current configuration version
+func (c *Chain) Version() int {
+	return c.version
+}
+
+// index returns position of this node in chain, -1 if not in chain
+func (c *Chain) index() int {
+	for i, id := range c.chain {
+		if id == c.ID() {
+			return i
+		}
+	}
+	return -1
+}
+
+// successor returns the next replica down the chain, empty if this node is tail
+func (c *Chain) successor() paxi.ID {
+	i := c.index()
+	if i < 0 || i == len(c.chain)-1 {
+		return ""
+	}
+	return c.chain[i+1]
+}
+
+// predecessor returns the previous replica up the chain, empty if this node is head
+func (c *Chain) predecessor() paxi.ID {
+	i := c.index()
+	if i <= 0 {
+		return ""
+	}
+	return c.chain[i-1]
+}
+
+// HandleRequest serves reads at tail and writes at head, otherwise forwards the request
+func (c *Chain) HandleRequest(r paxi.Request) {
+	if r.Command.IsRead() {
+		if !c.IsTail() {
+			go c.Forward(c.Tail(), r)
+			return
+		}
+		r.Reply(c.reply(r.Command, c.Get(r.Command.Key)))
+		return
+	}
+
+	if !c.IsHead() {
+		go c.Forward(c.Head(), r)
+		return
+	}
+	u := Update{
+		Seq:     c.applied + 1,
+		Command: r.Command,
+	}
+	c.requests[u.Seq] = &r
+	c.apply(u)
+}
+
+func (c *Chain) reply(cmd paxi.Command, v paxi.Value) paxi.Reply {
+	return paxi.Reply{
+		Command: cmd,
+		Value:   v,
+		Properties: map[string]string{
+			HTTPHeaderHead: string(c.Head()),
+			HTTPHeaderTail: string(c.Tail()),
+		},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// HandleUpdate handles Update message from predecessor
+func (c *Chain) HandleUpdate(m Update) {
+	// sent by replica of old chain, or duplicate resent after reconfiguration
+	if m.Version < c.version || m.Seq <= c.applied {
+		return
+	}
+	c.apply(m)
+}
+
+// apply executes updates in sequence order and propagates them to successor,
+// tail commits every update it applies
+func (c *Chain) apply(u Update) {
+	c.updates[u.Seq] = u
+	for {
+		u, exists := c.updates[c.applied+1]
+		if !exists {
+			break
+		}
+		c.applied++
+		log.Debugf("Replica %s execute [s=%d, cmd=%v]", c.ID(), u.Seq, u.Command)
+		// sequence starts from 1 while database slots start from 0
+		c.ExecuteAt(u.Seq-1, u.Command)
+		if c.IsTail() {
+			c.commit(u.Seq)
+		} else if s := c.successor(); s != "" {
+			u.Version = c.version
+			c.Send(s, u)
+		}
+	}
+}
+
+// HandleAck handles Ack message from successor
+func (c *Chain) HandleAck(m Ack) {
+	if m.Version < c.version {
+		return
+	}
+	c.commit(m.Seq)
+}
+
+// commit replies to writes up to seq and acks predecessor
+func (c *Chain) commit(seq int) {
+	if seq <= c.acked {
+		return
+	}
+	for s := c.acked + 1; s <= seq; s++ {
+		if r, exists := c.requests[s]; exists {
+			r.Reply(c.reply(c.updates[s].Command, nil))
+			delete(c.requests, s)
+		}
+		delete(c.updates, s)
+	}
+	c.acked = seq
+	if p := c.predecessor(); p != "" {
+		c.Send(p, Ack{Version: c.version, Seq: seq})
+	}
+}
+
+// HandleReconfigure installs new chain of higher version, stale versions are rejected
+func (c *Chain) HandleReconfigure(m Reconfigure) {
+	if m.Version <= c.version {
+		return
+	}
+	log.Infof("Replica %s installs chain %v of version %d", c.ID(), m.Chain, m.Version)
+	c.version = m.Version
+	c.chain = m.Chain
+	if c.index() < 0 {
+		return
+	}
+
+	if c.IsTail() {
+		// updates applied by new tail are committed
+		c.commit(c.applied)
+	} else {
+		// successor may have missed updates sent to the removed replica
+		for s := c.acked + 1; s <= c.applied; s++ {
+			u := c.updates[s]
+			u.Version = c.version
+			c.Send(c.successor(), u)
+		}
+	}
+	// predecessor may have missed acks sent by the removed replica, or rejected acks of the older version
+	if p := c.predecessor(); p != "" && c.acked > 0 {
+		c.Send(p, Ack{Version: c.version, Seq: c.acked})
+	}
+}
+
+// HandleHeartbeat records replica m.ID alive, the configuration master reconfigures chain if any replica failed
+func (c *Chain) HandleHeartbeat(m Heartbeat) {
+	if c.ID() != c.master {
+		return
+	}
+	c.alive[m.ID] = time.Now()
+	c.reconfigure()
+}
+
+// reconfigure removes failed replicas from chain in the next version, it is only run by the configuration master.
+// Replicas are assumed to be fail-stop, a removed replica never joins the chain again.
+func (c *Chain) reconfigure() {
+	live := make([]paxi.ID, 0, len(c.chain))
+	for _, id := range c.chain {
+		if time.Since(c.alive[id]) < *timeout {
+			live = append(live, id)
+		}
+	}
+	if len(live) == len(c.chain) || len(live) == 0 {
+		return
+	}
+	m := Reconfigure{
+		Version: c.version + 1,
+		Chain:   live,
+	}
+	c.Broadcast(m)
+	c.HandleReconfigure(m)
+}
@@ -0,0 +1,105 @@
+package chain
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+func TestReconfigure(t *testing.T) {
+	ids := []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}
+	flag.Set("heartbeat", "10ms")
+	flag.Set("failure_timeout", "50ms")
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	replicas := make(map[paxi.ID]*Replica)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+
+	type state struct {
+		version int
+		chain   []paxi.ID
+		acked   int
+		pending int
+		value   paxi.Value
+	}
+	get := func(id paxi.ID, k paxi.Key) (s state) {
+		nodes[id].Do(func() {
+			c := replicas[id].Chain
+			s = state{c.version, c.chain, c.acked, len(c.updates), c.Get(k)}
+		})
+		return
+	}
+	request := func(id paxi.ID, c paxi.Command) paxi.Value {
+		return nodes[id].Call(t, c).Value
+	}
+	// reconfigured waits for the configuration master to install version
+	reconfigured := func(version int) {
+		paxitest.Eventually(t, func() error {
+			if v := get("1.1", 0).version; v < version {
+				return fmt.Errorf("configuration master has version %d, expected %d", v, version)
+			}
+			return nil
+		})
+	}
+
+	// configuration master is outside of the chain and forwards requests
+	if s := get("1.1", 0); replicas["1.1"].Head() != "1.2" || len(s.chain) != 4 {
+		t.Fatalf("initial chain %v with configuration master 1.1", s.chain)
+	}
+	request("1.1", paxi.Command{Key: 1, Value: paxi.Value("1")})
+
+	// middle replica fails, writes are committed once configuration master removes it from chain
+	nodes["1.3"].Fail(true)
+	for i := 2; i <= 5; i++ {
+		request("1.1", paxi.Command{Key: paxi.Key(i), Value: paxi.Value(strconv.Itoa(i))})
+	}
+	head, tail := get("1.2", 5), get("1.5", 5)
+	if head.version != 1 || tail.version != 1 {
+		t.Errorf("expected version 1, head has %d and tail has %d", head.version, tail.version)
+	}
+	if head.acked != 5 || head.pending != 0 || tail.pending != 0 {
+		t.Errorf("head acked %d, updates not garbage collected, head has %d and tail has %d", head.acked, head.pending, tail.pending)
+	}
+	if string(tail.value) != "5" {
+		t.Errorf("tail reads %q, expected %q", tail.value, "5")
+	}
+
+	// head fails, configuration master makes its successor the new head
+	nodes["1.2"].Fail(true)
+	reconfigured(2)
+	request("1.1", paxi.Command{Key: 6, Value: paxi.Value("6")})
+	if v := request("1.1", paxi.Command{Key: 6}); string(v) != "6" {
+		t.Errorf("read of key 6 = %q, expected 6", v)
+	}
+	if s := get("1.5", 6); replicas["1.5"].Head() != "1.4" || len(s.chain) != 2 || s.version != 2 {
+		t.Errorf("tail has chain %v of version %d", s.chain, s.version)
+	}
+
+	// update of stale version from removed replica is rejected
+	nodes["1.5"].Do(func() {
+		replicas["1.5"].HandleUpdate(Update{Version: 1, Seq: 7, Command: paxi.Command{Key: 7, Value: paxi.Value("7")}})
+	})
+	if v := get("1.5", 7).value; v != nil {
+		t.Errorf("tail applied update of stale version, reads %q", v)
+	}
+
+	// tail is partitioned, only the master reconfigures and the partitioned tail keeps its chain
+	nodes["1.5"].Fail(true)
+	request("1.1", paxi.Command{Key: 8, Value: paxi.Value("8")})
+	if s := get("1.4", 8); s.version != 3 || len(s.chain) != 1 || string(s.value) != "8" {
+		t.Errorf("head has chain %v of version %d and reads %q", s.chain, s.version, s.value)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if s := get("1.5", 8); s.version != 2 || len(s.chain) != 2 {
+		t.Errorf("partitioned tail reconfigured to chain %v of version %d", s.chain, s.version)
+	}
+}
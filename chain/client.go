@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"sync"
+
+	"github.com/ailidani/paxi"
+)
+
+// Client sends writes to head and reads to tail of the chain learned from replies
+// Client is safe for concurrent use by multiple goroutines
+type Client struct {
+	*paxi.HTTPClient
+
+	mu   sync.RWMutex // protects head and tail
+	head paxi.ID
+	tail paxi.ID
+}
+
+// NewClient creates a new chain client, head and tail are initially the first and last of initial chain
+func NewClient(id paxi.ID) *Client {
+	_, chain := configuration()
+	return &Client{
+		HTTPClient: paxi.NewHTTPClient(id),
+		head:       chain[0],
+		tail:       chain[len(chain)-1],
+	}
+}
+
+// Get implements paxi.Client interface
+func (c *Client) Get(key paxi.Key) (paxi.Value, error) {
+	c.mu.RLock()
+	tail := c.tail
+	c.mu.RUnlock()
+	// tail may have failed, the connected replica forwards to current tail
+	v, meta, err := c.RESTFailover(key, nil, tail, c.ID)
+	c.update(meta)
+	return v, err
+}
+
+// Put implements paxi.Client interface
+func (c *Client) Put(key paxi.Key, value paxi.Value) error {
+	c.mu.RLock()
+	head := c.head
+	c.mu.RUnlock()
+	// head may have failed, the connected replica forwards to current head
+	_, meta, err := c.RESTFailover(key, value, head, c.ID)
+	c.update(meta)
+	return err
+}
+
+// update keeps head and tail of latest reply
+func (c *Client) update(meta map[string]string) {
+	head, tail := meta[HTTPHeaderHead], meta[HTTPHeaderTail]
+	if head == "" || tail == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = paxi.ID(head)
+	c.tail = paxi.ID(tail)
+}
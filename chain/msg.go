@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+)
+
+func init() {
+	gob.Register(Update{})
+	gob.Register(Ack{})
+	gob.Register(Heartbeat{})
+	gob.Register(Reconfigure{})
+}
+
+// Update propagates a write from head down the chain
+type Update struct {
+	Version int // configuration version of sender
+	Seq     int
+	Command paxi.Command
+}
+
+func (m Update) String() string {
+	return fmt.Sprintf("Update {v=%d s=%d cmd=%v}", m.Version, m.Seq, m.Command)
+}
+
+// Ack propagates from tail up the chain, all updates up to Seq are committed
+type Ack struct {
+	Version int // configuration version of sender
+	Seq     int
+}
+
+func (m Ack) String() string {
+	return fmt.Sprintf("Ack {v=%d s=%d}", m.Version, m.Seq)
+}
+
+// Heartbeat is sent periodically by every replica to the configuration master
+type Heartbeat struct {
+	ID paxi.ID
+}
+
+func (m Heartbeat) String() string {
+	return fmt.Sprintf("Heartbeat {id=%s}", m.ID)
+}
+
+// Reconfigure installs a new chain of replicas in order from head to tail, versions are assigned by the configuration master
+type Reconfigure struct {
+	Version int
+	Chain   []paxi.ID
+}
+
+func (m Reconfigure) String() string {
+	return fmt.Sprintf("Reconfigure {v=%d chain=%v}", m.Version, m.Chain)
+}
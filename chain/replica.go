@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"flag"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+var heartbeat = flag.Duration("heartbeat", 100*time.Millisecond, "interval of chain replica heartbeats")
+var timeout = flag.Duration("failure_timeout", time.Second, "chain replica without heartbeat for timeout is removed from chain")
+var master = flag.String("chain_master", "", "id of the configuration master outside of the chain, the first replica in sorted order if empty")
+
+const (
+	HTTPHeaderHead = "Head"
+	HTTPHeaderTail = "Tail"
+)
+
+// Replica for one chain replication instance
+type Replica struct {
+	paxi.Node
+	*Chain
+}
+
+// NewReplica generates new chain replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new chain replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.Chain = NewChain(r)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(Update{}, r.HandleUpdate)
+	r.Register(Ack{}, r.HandleAck)
+	r.Register(Heartbeat{}, r.HandleHeartbeat)
+	r.Register(Reconfigure{}, r.HandleReconfigure)
+	return r
+}
+
+// Run starts sending heartbeats to the configuration master and runs the node
+func (r *Replica) Run() {
+	go func() {
+		for range time.Tick(*heartbeat) {
+			if r.ID() == r.Master() {
+				r.Local(Heartbeat{ID: r.ID()})
+			} else {
+				r.Send(r.Master(), Heartbeat{ID: r.ID()})
+			}
+		}
+	}()
+	r.Node.Run()
+}
+
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+	r.Chain.HandleRequest(m)
+}
@@ -12,6 +12,12 @@ type checker struct {
 	*lib.Graph
 }
 
+// anomaly is a read that makes the history not linearizable, with the cycle found in the graph when it is added
+type anomaly struct {
+	read  *operation
+	cycle []*operation
+}
+
 func newChecker() *checker {
 	return &checker{
 		Graph: lib.NewGraph(),
@@ -66,10 +72,10 @@ func (c *checker) merge(read, write *operation) {
 	c.Graph.Remove(read)
 }
 
-func (c *checker) linearizable(history []*operation) []*operation {
+func (c *checker) linearizable(history []*operation) []anomaly {
 	c.clear()
 	sort.Sort(byTime(history))
-	anomaly := make([]*operation, 0)
+	anomalies := make([]anomaly, 0)
 	for i, o := range history {
 		c.add(o)
 		// o is read operation
@@ -89,7 +95,12 @@ func (c *checker) linearizable(history []*operation) []*operation {
 
 			cycle := c.Graph.Cycle()
 			if cycle != nil {
-				anomaly = append(anomaly, o)
+				a := anomaly{read: o}
+				for _, v := range cycle {
+					a.cycle = append(a.cycle, v.(*operation))
+				}
+				sort.Sort(byTime(a.cycle))
+				anomalies = append(anomalies, a)
 				for _, u := range cycle {
 					for _, v := range cycle {
 						if c.Graph.From(u).Has(v) && u.(*operation).start > v.(*operation).end {
@@ -100,5 +111,5 @@ func (c *checker) linearizable(history []*operation) []*operation {
 			}
 		}
 	}
-	return anomaly
+	return anomalies
 }
@@ -4,11 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/ailidani/paxi"
 )
 
 var file = flag.String("log", "log.csv", "")
+var exact = flag.Bool("exact", false, "exhaustive linearizability check with minimal counterexample of each key")
+var report = flag.Bool("report", false, "write anomalies and timeline of heuristic linearizability check next to log file")
+var model = flag.String("model", "linearizable", "consistency model to check: linearizable, sequential, causal, ryw (read-your-writes) or mr (monotonic reads)")
 
 func main() {
 	flag.Parse()
@@ -20,7 +25,37 @@ func main() {
 		log.Fatal(err)
 	}
 
-	n := h.Linearizable()
+	var violations []paxi.Violation
+	switch *model {
+	case "linearizable":
+		if !*exact {
+			var n int
+			if *report {
+				n, err = h.WriteReport(strings.TrimSuffix(*file, filepath.Ext(*file)))
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				n = h.Linearizable()
+			}
+			fmt.Println(n)
+			return
+		}
+		violations = h.LinearizableExact()
+	case "sequential":
+		violations = h.SequentiallyConsistent()
+	case "causal":
+		violations = h.CausallyConsistent()
+	case "ryw":
+		violations = h.ReadYourWrites()
+	case "mr":
+		violations = h.MonotonicReads()
+	default:
+		log.Fatalf("unknown consistency model %s", *model)
+	}
 
-	fmt.Println(n)
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	fmt.Println(len(violations))
 }
@@ -1,6 +1,7 @@
 package paxi
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -10,7 +11,9 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ailidani/paxi/lib"
 	"github.com/ailidani/paxi/log"
@@ -31,6 +34,7 @@ type AdminClient interface {
 }
 
 // HTTPClient inplements Client interface with REST API
+// HTTPClient is safe for concurrent use by multiple goroutines
 type HTTPClient struct {
 	Addrs  map[ID]string
 	HTTP   map[ID]string
@@ -38,18 +42,74 @@ type HTTPClient struct {
 	N      int // total number of nodes
 	LocalN int // number of nodes in local zone
 
-	CID int // command id
 	*http.Client
+	*clientSessions
+
+	pool map[ID]*http.Client // connection pool per replica
+	sync.Mutex
+}
+
+// clientSession allocates command ids for one client session,
+// a session is used by one command at a time so that its ids reach replicas in order
+type clientSession struct {
+	id  ID
+	cid int
+}
+
+// clientSessions is a pool of idle client sessions, one session is created
+// for each concurrent command so that callers never share a session
+type clientSessions struct {
+	lock   sync.Mutex
+	prefix string           // unique prefix of session ids
+	count  int64            // number of sessions created
+	idle   []*clientSession // idle sessions
+}
+
+func newClientSessions(id ID) *clientSessions {
+	return &clientSessions{
+		prefix: string(id) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		idle:   make([]*clientSession, 0),
+	}
+}
+
+// acquire takes an idle session or creates a new one, and allocates the next command id in it
+func (p *clientSessions) acquire() *clientSession {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var s *clientSession
+	if n := len(p.idle); n > 0 {
+		s = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	} else {
+		p.count++
+		s = &clientSession{id: ID(p.prefix + "-" + strconv.FormatInt(p.count, 10))}
+	}
+	s.cid++
+	return s
+}
+
+// release returns session s to idle sessions once its command completes,
+// a session whose command failed is dropped since the command may never be applied,
+// which would leave a gap in command ids of the session at replicas
+func (p *clientSessions) release(s *clientSession, err error) {
+	if err != nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.idle = append(p.idle, s)
 }
 
 // NewHTTPClient creates a new Client from config
 func NewHTTPClient(id ID) *HTTPClient {
 	c := &HTTPClient{
-		ID:     id,
-		N:      len(config.Addrs),
-		Addrs:  config.Addrs,
-		HTTP:   config.HTTPAddrs,
-		Client: &http.Client{},
+		ID:             id,
+		N:              len(config.Addrs),
+		Addrs:          config.Addrs,
+		HTTP:           config.HTTPAddrs,
+		Client:         &http.Client{},
+		clientSessions: newClientSessions(id),
+		pool:           make(map[ID]*http.Client),
 	}
 	if id != "" {
 		i := 0
@@ -67,7 +127,6 @@ func NewHTTPClient(id ID) *HTTPClient {
 // Get gets value of given key (use REST)
 // Default implementation of Client interface
 func (c *HTTPClient) Get(key Key) (Value, error) {
-	c.CID++
 	v, _, err := c.RESTGet(c.ID, key)
 	return v, err
 }
@@ -75,12 +134,29 @@ func (c *HTTPClient) Get(key Key) (Value, error) {
 // Put puts new key value pair and return previous value (use REST)
 // Default implementation of Client interface
 func (c *HTTPClient) Put(key Key, value Value) error {
-	c.CID++
 	_, _, err := c.RESTPut(c.ID, key, value)
 	return err
 }
 
-func (c *HTTPClient) GetURL(id ID, key Key) string {
+// conn returns the http client of connection pool to replica id
+func (c *HTTPClient) conn(id ID) *http.Client {
+	c.Lock()
+	defer c.Unlock()
+	client, exists := c.pool[id]
+	if !exists {
+		client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConnsPerHost: config.ClientPoolSize,
+			},
+		}
+		c.pool[id] = client
+	}
+	return client
+}
+
+// target returns the replica id to send to, picks a random replica if id is empty
+func (c *HTTPClient) target(id ID) ID {
 	if id == "" {
 		for id = range c.HTTP {
 			if c.ID == "" || id.Zone() == c.ID.Zone() {
@@ -96,13 +172,56 @@ func (c *HTTPClient) GetURL(id ID, key Key) string {
 			i--
 		}
 	}
-	return c.HTTP[id] + "/" + strconv.Itoa(int(key))
+	return id
+}
+
+func (c *HTTPClient) GetURL(id ID, key Key) string {
+	return c.HTTP[c.target(id)] + "/" + strconv.Itoa(int(key))
 }
 
 // rest accesses server's REST API with url = http://ip:port/key
-// if value == nil, it's a read
+// if value == nil, it's a read.
+// Failed attempts are retried up to config.ClientRetry times with the same command id,
+// so that replicas execute the command only once however many attempts reach them
 func (c *HTTPClient) rest(id ID, key Key, value Value) (Value, map[string]string, error) {
-	// get url
+	return c.RESTFailover(key, value, id)
+}
+
+// RESTFailover issues command to the first of ids, and fails over to the next id once retries of one are exhausted,
+// all attempts carry the same session and command id, so the command is executed once whichever replicas it reaches.
+// if value == nil, it's a read.
+func (c *HTTPClient) RESTFailover(key Key, value Value, ids ...ID) (Value, map[string]string, error) {
+	session := c.acquire()
+
+	var v Value
+	var metadata map[string]string
+	var err error
+	defer func() { c.release(session, err) }()
+	for _, id := range ids {
+		// get url
+		id = c.target(id)
+		for i := 0; ; i++ {
+			var retry bool
+			v, metadata, retry, err = c.do(id, key, value, session)
+			if !retry {
+				return v, metadata, err
+			}
+			if i >= config.ClientRetry {
+				break
+			}
+			log.Debugf("retry command %d of session %s: %v", session.cid, session.id, err)
+			time.Sleep(retryDelay * time.Duration(i+1))
+		}
+	}
+	return v, metadata, err
+}
+
+// retryDelay is the delay before the first retry of a failed request, it grows linearly with attempts
+const retryDelay = 10 * time.Millisecond
+
+// do makes one attempt of command of session, and returns whether it failed in a way worth retrying,
+// i.e. the replica is unreachable or failed to execute the command
+func (c *HTTPClient) do(id ID, key Key, value Value, session *clientSession) (Value, map[string]string, bool, error) {
 	url := c.GetURL(id, key)
 
 	method := http.MethodGet
@@ -114,16 +233,16 @@ func (c *HTTPClient) rest(id ID, key Key, value Value) (Value, map[string]string
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		log.Error(err)
-		return nil, nil, err
+		return nil, nil, false, err
 	}
-	req.Header.Set(HTTPClientID, string(c.ID))
-	req.Header.Set(HTTPCommandID, strconv.Itoa(c.CID))
+	req.Header.Set(HTTPClientID, string(session.id))
+	req.Header.Set(HTTPCommandID, strconv.Itoa(session.cid))
 	// r.Header.Set(HTTPTimestamp, strconv.FormatInt(time.Now().UnixNano(), 10))
 
-	rep, err := c.Client.Do(req)
+	rep, err := c.conn(id).Do(req)
 	if err != nil {
 		log.Error(err)
-		return nil, nil, err
+		return nil, nil, true, err
 	}
 	log.Debugf("node received rep %v", rep)
 	defer rep.Body.Close()
@@ -138,20 +257,20 @@ func (c *HTTPClient) rest(id ID, key Key, value Value) (Value, map[string]string
 		b, err := ioutil.ReadAll(rep.Body)
 		if err != nil {
 			log.Error(err)
-			return nil, metadata, err
+			return nil, metadata, true, err
 		}
 		if value == nil {
 			log.Debugf("node=%v type=%s key=%v value=%x", id, method, key, Value(b))
 		} else {
 			log.Debugf("node=%v type=%s key=%v value=%x", id, method, key, value)
 		}
-		return Value(b), metadata, nil
+		return Value(b), metadata, false, nil
 	}
 
 	// http call failed
 	dump, _ := httputil.DumpResponse(rep, true)
 	log.Debugf("%q", dump)
-	return nil, metadata, errors.New(rep.Status)
+	return nil, metadata, rep.StatusCode >= http.StatusInternalServerError, errors.New(rep.Status)
 }
 
 // RESTGet issues a http call to node and return value and headers
@@ -159,6 +278,26 @@ func (c *HTTPClient) RESTGet(id ID, key Key) (Value, map[string]string, error) {
 	return c.rest(id, key, nil)
 }
 
+// GetAt reads value of key as of log slot from replica id, without going through the protocol
+func (c *HTTPClient) GetAt(id ID, key Key, slot int) (Value, error) {
+	id = c.target(id)
+	url := c.GetURL(id, key) + "?slot=" + strconv.Itoa(slot)
+	rep, err := c.conn(id).Get(url)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	defer rep.Body.Close()
+	b, err := ioutil.ReadAll(rep.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rep.StatusCode != http.StatusOK {
+		return nil, errors.New(rep.Status)
+	}
+	return Value(b), nil
+}
+
 // RFLGet issues a http call to node and return value and headers
 func (c *HTTPClient) RFLGet(id ID, key Key, keyslot int, nodehole string) (Value, map[string]string, error) {
 
@@ -170,12 +309,14 @@ func (c *HTTPClient) RFLGet(id ID, key Key, keyslot int, nodehole string) (Value
 		log.Error(err)
 		return nil, nil, err
 	}
-	req.Header.Set(HTTPClientID, string(c.ID))
-	req.Header.Set(HTTPCommandID, strconv.Itoa(c.CID))
+	session := c.acquire()
+	defer func() { c.release(session, err) }()
+	req.Header.Set(HTTPClientID, string(session.id))
+	req.Header.Set(HTTPCommandID, strconv.Itoa(session.cid))
 	req.Header.Set("keyslot", strconv.Itoa(keyslot))
 	req.Header.Set("NodeHoles", nodehole)
 
-	rep, err := c.Client.Do(req)
+	rep, err := c.conn(id).Do(req)
 	if err != nil {
 		log.Error(err)
 		return nil, nil, err
@@ -203,14 +344,20 @@ func (c *HTTPClient) RESTPut(id ID, key Key, value Value) (Value, map[string]str
 
 func (c *HTTPClient) json(id ID, key Key, value Value) (Value, error) {
 	url := c.HTTP[id]
+	session := c.acquire()
+	var err error
+	defer func() { c.release(session, err) }()
 	cmd := Command{
 		Key:       key,
 		Value:     value,
-		ClientID:  c.ID,
-		CommandID: c.CID,
+		ClientID:  session.id,
+		CommandID: session.cid,
 	}
 	data, err := json.Marshal(cmd)
-	res, err := c.Client.Post(url, "json", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.conn(id).Post(url, "json", bytes.NewBuffer(data))
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -223,7 +370,8 @@ func (c *HTTPClient) json(id ID, key Key, value Value) (Value, error) {
 	}
 	dump, _ := httputil.DumpResponse(res, true)
 	log.Debugf("%q", dump)
-	return nil, errors.New(res.Status)
+	err = errors.New(res.Status)
+	return nil, err
 }
 
 // JSONGet posts get request in json format to server url
@@ -325,6 +473,56 @@ func (c *HTTPClient) QuorumPut(key Key, value Value) {
 	wait.Wait()
 }
 
+// Watch subscribes to write commands executed on key at replica c.ID
+func (c *HTTPClient) Watch(key Key) (*Watcher, error) {
+	return c.WatchRange(key, key)
+}
+
+// WatchRange subscribes to write commands executed on keys in range [from, to] at replica c.ID,
+// the channel of returned watcher is closed when the stream ends or the watcher stops
+func (c *HTTPClient) WatchRange(from, to Key) (*Watcher, error) {
+	url := c.HTTP[c.target(c.ID)] + "/watch?from=" + strconv.Itoa(int(from)) + "&to=" + strconv.Itoa(int(to))
+	res, err := c.Client.Get(url)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.New(res.Status)
+	}
+
+	w := newWatcher(from, to, config.ChanBufferSize)
+	done := make(chan struct{})
+	w.stop = func() {
+		close(done)
+		res.Body.Close()
+	}
+	go func() {
+		defer close(w.c)
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(make([]byte, 4096), 64*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &e); err != nil {
+				log.Error(err)
+				continue
+			}
+			select {
+			case w.c <- e:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
 // Consensus collects /history/key from every node and compare their values
 func (c *HTTPClient) Consensus(k Key) bool {
 	h := make(map[ID][]Value)
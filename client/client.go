@@ -5,7 +5,10 @@ import (
 	"flag"
 
 	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/chain"
 	"github.com/ailidani/paxi/log"
+	"github.com/ailidani/paxi/paxos"
+	"github.com/ailidani/paxi/raft"
 	paxos2bro "github.com/ailidani/paxi/rlpaxos"
 )
 
@@ -14,6 +17,8 @@ var algorithm = flag.String("algorithm", "", "Client API type [paxos, chain]")
 var load = flag.Bool("load", false, "Load K keys into DB")
 var master = flag.String("master", "", "Master address.")
 var path = flag.String("historypath", "/bin/history", "client operation history file paht.")
+var protocol = flag.String("protocol", "http", "client protocol to replicas [http, tcp], tcp is not supported by algorithm specific clients")
+var pipeline = flag.Bool("pipeline", false, "pipeline all commands over one http connection to the replica, not supported by algorithm specific clients")
 
 // db implements Paxi.DB interface for benchmarking
 type db struct {
@@ -32,7 +37,7 @@ func (d *db) Read(k int) (int, error) {
 	key := paxi.Key(k)
 	v, err := d.Get(key)
 	if len(v) == 0 {
-		return 0, nil
+		return 0, err
 	}
 	x, _ := binary.Uvarint(v)
 	return int(x), err
@@ -46,6 +51,63 @@ func (d *db) Write(k, v int) error {
 	return err
 }
 
+// ReadFrom implements paxi.ReplicaDB interface, replica is known from reply of http client only
+func (d *db) ReadFrom(k int) (int, paxi.ID, error) {
+	c, ok := d.Client.(*paxi.HTTPClient)
+	if !ok {
+		v, err := d.Read(k)
+		return v, "", err
+	}
+	v, meta, err := c.RESTGet(c.ID, paxi.Key(k))
+	if len(v) == 0 {
+		return 0, paxi.ID(meta[paxi.HTTPReplica]), err
+	}
+	x, _ := binary.Uvarint(v)
+	return int(x), paxi.ID(meta[paxi.HTTPReplica]), err
+}
+
+// WriteTo implements paxi.ReplicaDB interface, replica is known from reply of http client only
+func (d *db) WriteTo(k, v int) (paxi.ID, error) {
+	c, ok := d.Client.(*paxi.HTTPClient)
+	if !ok {
+		return "", d.Write(k, v)
+	}
+	value := make([]byte, 10)
+	binary.PutUvarint(value, uint64(v))
+	_, meta, err := c.RESTPut(c.ID, paxi.Key(k), value)
+	return paxi.ID(meta[paxi.HTTPReplica]), err
+}
+
+// asyncDB implements Paxi.DB interface with pipelined commands,
+// every benchmark worker shares one connection to the replica
+type asyncDB struct {
+	*paxi.AsyncClient
+}
+
+func (d *asyncDB) Init() error {
+	return d.Connect()
+}
+
+func (d *asyncDB) Stop() error {
+	return d.Close()
+}
+
+func (d *asyncDB) Read(k int) (int, error) {
+	v, err := d.GetAsync(paxi.Key(k)).Get()
+	if len(v) == 0 {
+		return 0, err
+	}
+	x, _ := binary.Uvarint(v)
+	return int(x), err
+}
+
+func (d *asyncDB) Write(k, v int) error {
+	value := make([]byte, 10)
+	binary.PutUvarint(value, uint64(v))
+	_, err := d.PutAsync(paxi.Key(k), value).Get()
+	return err
+}
+
 func main() {
 	paxi.Init()
 
@@ -56,13 +118,43 @@ func main() {
 	d := new(db)
 	switch *algorithm {
 
+	case "paxos":
+		d.Client = paxos.NewClient(paxi.ID(*id))
 	case "paxos2bro":
 		d.Client = paxos2bro.NewClient(paxi.ID(*id))
-	default:
-		d.Client = paxi.NewHTTPClient(paxi.ID(*id))
+	case "chain":
+		d.Client = chain.NewClient(paxi.ID(*id))
+	case "raft":
+		d.Client = raft.NewClient(paxi.ID(*id))
+	}
+	// algorithm specific clients only speak http, one command at a time
+	if d.Client != nil && (*protocol != "http" || *pipeline) {
+		log.Fatalf("%s client cannot be used with -protocol %s or -pipeline", *algorithm, *protocol)
 	}
 
-	b := paxi.NewBenchmark(d)
+	var b *paxi.Benchmark
+	switch {
+	case *pipeline && *protocol != "http":
+		log.Fatalf("-pipeline is over http stream connection, it cannot be used with -protocol %s", *protocol)
+	case *pipeline:
+		b = paxi.NewBenchmark(&asyncDB{paxi.NewAsyncClient(paxi.ID(*id))})
+	case *protocol == "tcp":
+		c := paxi.NewBinaryClient(paxi.ID(*id))
+		if err := c.Connect(); err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		d.Client = c
+		b = paxi.NewBenchmark(d)
+	case *protocol != "http":
+		log.Fatalf("unknown protocol %s", *protocol)
+	default:
+		if d.Client == nil {
+			d.Client = paxi.NewHTTPClient(paxi.ID(*id))
+		}
+		b = paxi.NewBenchmark(d)
+	}
+	b.ID = paxi.ID(*id)
 	if *load {
 		b.Load()
 	} else {
@@ -0,0 +1,157 @@
+package paxi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHTTPClientConcurrent(t *testing.T) {
+	var lock sync.Mutex
+	last := make(map[string]int)      // last command id per session
+	inflight := make(map[string]bool) // session has an outstanding command
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HTTPClientID)
+		cid, err := strconv.Atoi(r.Header.Get(HTTPCommandID))
+		if err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		if inflight[id] {
+			t.Errorf("session %s has concurrent commands", id)
+		}
+		if cid <= last[id] {
+			t.Errorf("session %s command id %d not after %d", id, cid, last[id])
+		}
+		inflight[id] = true
+		last[id] = cid
+		lock.Unlock()
+
+		io.WriteString(w, r.URL.Path[1:])
+
+		lock.Lock()
+		inflight[id] = false
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	c := NewHTTPClient("1.1")
+	c.HTTP = map[ID]string{"1.1": server.URL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				k := Key(i*100 + j)
+				var v Value
+				var err error
+				if j%2 == 0 {
+					err = c.Put(k, Value("v"))
+				} else {
+					v, err = c.Get(k)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if j%2 == 1 && string(v) != strconv.Itoa(int(k)) {
+					t.Errorf("Get(%v) = %s", k, v)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(last) > 50 {
+		t.Errorf("%d sessions created for 50 goroutines", len(last))
+	}
+}
+
+func TestHTTPClientRetry(t *testing.T) {
+	var lock sync.Mutex
+	attempts := make([]string, 0) // session and command id of every attempt
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts = append(attempts, r.Header.Get(HTTPClientID)+"/"+r.Header.Get(HTTPCommandID))
+		n := len(attempts)
+		lock.Unlock()
+		if n == 1 {
+			http.Error(w, "timeout", http.StatusInternalServerError)
+			return
+		}
+		if n == 3 {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "v")
+	}))
+	defer server.Close()
+
+	c := NewHTTPClient("1.1")
+	c.HTTP = map[ID]string{"1.1": server.URL}
+
+	if err := c.Put(1, Value("v")); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0] != attempts[1] {
+		t.Fatalf("retry of failed command has attempts %v, want the same command id", attempts)
+	}
+
+	// client errors are not retried
+	if _, err := c.Get(1); err == nil {
+		t.Errorf("bad request succeeded")
+	}
+	if len(attempts) != 3 || attempts[2] == attempts[1] {
+		t.Errorf("attempts %v, want a new command id for the next command and no retry", attempts)
+	}
+
+	// session of the failed command is not used again
+	if _, err := c.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 4 || strings.Split(attempts[3], "/")[0] == strings.Split(attempts[2], "/")[0] {
+		t.Errorf("attempts %v, want a new session after failed command", attempts)
+	}
+}
+
+func TestHTTPClientFailover(t *testing.T) {
+	var lock sync.Mutex
+	attempts := make(map[ID][]string) // session and command id of attempts per replica
+	handler := func(id ID, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			attempts[id] = append(attempts[id], r.Header.Get(HTTPClientID)+"/"+r.Header.Get(HTTPCommandID))
+			lock.Unlock()
+			if status != http.StatusOK {
+				http.Error(w, "failed", status)
+				return
+			}
+			io.WriteString(w, "v")
+		}
+	}
+	failed := httptest.NewServer(handler("1.1", http.StatusInternalServerError))
+	defer failed.Close()
+	server := httptest.NewServer(handler("1.2", http.StatusOK))
+	defer server.Close()
+
+	c := NewHTTPClient("1.2")
+	c.HTTP = map[ID]string{"1.1": failed.URL, "1.2": server.URL}
+
+	if _, _, err := c.RESTFailover(1, Value("v"), "1.1", "1.2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts["1.1"]) != config.ClientRetry+1 || len(attempts["1.2"]) != 1 {
+		t.Fatalf("attempts %v, want retries of 1.1 before failing over to 1.2", attempts)
+	}
+	for _, a := range attempts["1.1"] {
+		if a != attempts["1.2"][0] {
+			t.Errorf("attempts %v, want the same session and command id on failover", attempts)
+		}
+	}
+}
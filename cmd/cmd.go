@@ -9,6 +9,9 @@ import (
 	"strings"
 
 	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/chain"
+	"github.com/ailidani/paxi/paxos"
+	"github.com/ailidani/paxi/raft"
 )
 
 var id = flag.String("id", "", "node id this client connects to")
@@ -108,6 +111,12 @@ func main() {
 
 	switch *algorithm {
 
+	case "paxos":
+		client = paxos.NewClient(paxi.ID(*id))
+	case "chain":
+		client = chain.NewClient(paxi.ID(*id))
+	case "raft":
+		client = raft.NewClient(paxi.ID(*id))
 	default:
 		client = paxi.NewHTTPClient(paxi.ID(*id))
 	}
@@ -14,6 +14,7 @@ var configFile = flag.String("config", "config.json", "Configuration file for pa
 type Config struct {
 	Addrs     map[ID]string `json:"address"`      // address for node communication
 	HTTPAddrs map[ID]string `json:"http_address"` // address for client server communication
+	TCPAddrs  map[ID]string `json:"tcp_address"`  // address for binary client protocol, optional
 
 	Policy    string  `json:"policy"`    // leader change policy {consecutive, majority}
 	Threshold float64 `json:"threshold"` // threshold for policy in WPaxos {n consecutive or time interval in ms}
@@ -22,6 +23,17 @@ type Config struct {
 	BufferSize     int     `json:"buffer_size"`      // buffer size for maps
 	ChanBufferSize int     `json:"chan_buffer_size"` // buffer size for channels
 	MultiVersion   bool    `json:"multiversion"`     // create multi-version database
+	VersionHorizon int     `json:"version_horizon"`  // number of slots of old versions kept in multi-version database, keep all if 0
+	SessionTimeout int     `json:"session_timeout"`  // number of executed slots before an idle client session expires
+	Storage        string  `json:"storage"`          // storage engine of database {memory, disk}
+	StoragePath    string  `json:"storage_path"`     // directory of disk storage
+	StorageSync    int     `json:"storage_sync"`     // fsync disk storage after every n applied commands, never if 0
+	SnapshotSize   int     `json:"snapshot_size"`    // number of applied commands between storage snapshots
+	ClientPoolSize int     `json:"client_pool_size"` // number of idle http connections client keeps per replica
+	ClientRetry    int     `json:"client_retry"`     // number of times client retries a failed command with the same command id
+	ClientTimeout  int     `json:"client_timeout"`   // milliseconds before a pipelined command without reply fails, never if 0
+	ErasureData    int     `json:"erasure_data"`     // number of data shards of erasure coded values
+	ErasureParity  int     `json:"erasure_parity"`   // number of parity shards of erasure coded values
 	Benchmark      Bconfig `json:"benchmark"`        // benchmark configuration
 
 	// for future implementation
@@ -60,6 +72,17 @@ func MakeDefaultConfig() Config {
 		BufferSize:     1024,
 		ChanBufferSize: 1024,
 		MultiVersion:   false,
+		VersionHorizon: 0,
+		SessionTimeout: 100000,
+		Storage:        "memory",
+		StoragePath:    "data",
+		StorageSync:    1,
+		SnapshotSize:   100000,
+		ClientPoolSize: 1000,
+		ClientRetry:    2,
+		ClientTimeout:  10000,
+		ErasureData:    3,
+		ErasureParity:  2,
 		Benchmark:      DefaultBConfig(),
 	}
 }
@@ -104,7 +127,12 @@ func (c *Config) Load() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	c.count()
+}
 
+// count counts nodes and zones of addresses
+func (c *Config) count() {
+	c.n = 0
 	c.npz = make(map[int]int)
 	for id := range c.Addrs {
 		c.n++
@@ -113,6 +141,12 @@ func (c *Config) Load() {
 	c.z = len(c.npz)
 }
 
+// SetConfig replaces paxi package configuration with c instead of loading it from config file
+func SetConfig(c Config) {
+	c.count()
+	config = c
+}
+
 // Save saves configuration to file in JSON format
 func (c Config) Save() error {
 	file, err := os.Create(*configFile)
@@ -0,0 +1,326 @@
+package paxi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Checkers of consistency models weaker than linearizability.
+// Operations of one client session are issued sequentially, so session order is the invocation order.
+// Reads are matched to the write of the same value, which assumes written values of a key are unique,
+// a read of zero value reads the initial value of the key.
+// Reads that failed are ignored, writes that failed may or may not take effect at any time after invocation.
+
+// SequentiallyConsistent checks each key of the history for a total order of its operations that
+// respects session order, and returns the minimal counterexample of every key that is not.
+// Sequential consistency is not composable, this checks the per-key sub-histories only.
+func (h *History) SequentiallyConsistent() []Violation {
+	violations := make(chan *Violation)
+	h.RLock()
+	defer h.RUnlock()
+	for k, partition := range h.shard {
+		go func(k int, p []*operation) {
+			if sequential(p) {
+				violations <- nil
+				return
+			}
+			violations <- &Violation{Anomaly: "sequential", Key: k, Operations: minimize(p, sequential)}
+		}(k, checkable(partition))
+	}
+	result := make([]Violation, 0)
+	for range h.shard {
+		if v := <-violations; v != nil {
+			result = append(result, *v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// bySession groups operations by session in session order
+func bySession(ops []*operation) map[ID][]*operation {
+	s := make(map[ID][]*operation)
+	for _, o := range ops {
+		s[o.Client] = append(s[o.Client], o)
+	}
+	for _, ops := range s {
+		sort.Sort(byTime(ops))
+	}
+	return s
+}
+
+// sequential searches for an interleaving of sessions of operations on one key,
+// every indeterminate write is in a session of its own and may be left out
+func sequential(ops []*operation) bool {
+	ss := make([][]*operation, 0)
+	determinate := make([]*operation, 0, len(ops))
+	for _, o := range ops {
+		if o.indeterminate() {
+			ss = append(ss, []*operation{o})
+		} else {
+			determinate = append(determinate, o)
+		}
+	}
+	for _, s := range bySession(determinate) {
+		ss = append(ss, s)
+	}
+	pos := make([]int, len(ss))
+	// states known to fail
+	failed := make(map[string]bool)
+
+	var search func(state interface{}, n int) bool
+	search = func(state interface{}, n int) bool {
+		if n == len(determinate) {
+			return true
+		}
+		key := fmt.Sprint(pos, "|", state)
+		if failed[key] {
+			return false
+		}
+		for i, s := range ss {
+			if pos[i] == len(s) {
+				continue
+			}
+			o := s[pos[i]]
+			if ok, next := step(state, o); ok {
+				pos[i]++
+				found := false
+				if o.indeterminate() {
+					found = search(next, n)
+				} else {
+					found = search(next, n+1)
+				}
+				pos[i]--
+				if found {
+					return true
+				}
+			}
+		}
+		failed[key] = true
+		return false
+	}
+	return search(nil, 0)
+}
+
+// writes indexes write operations by key and value
+type writes map[int]map[string]*operation
+
+func newWrites(ops []*operation) writes {
+	w := make(writes)
+	for _, o := range ops {
+		if o.input == nil {
+			continue
+		}
+		if w[o.key] == nil {
+			w[o.key] = make(map[string]*operation)
+		}
+		w[o.key][fmt.Sprint(o.input)] = o
+	}
+	return w
+}
+
+// of returns the write read by read operation r, nil if r reads initial value, false if no write matches
+func (w writes) of(r *operation) (*operation, bool) {
+	if o, exists := w[r.key][fmt.Sprint(r.output)]; exists {
+		return o, true
+	}
+	return nil, zero(r.output)
+}
+
+// precedes returns true if write a is known to be ordered before write b, nil is the initial write
+func precedes(a, b *operation) bool {
+	if a == b || b == nil {
+		return false
+	}
+	if a == nil {
+		return true
+	}
+	if a.indeterminate() {
+		return false
+	}
+	return a.happenBefore(*b) || a.Client == b.Client && a.start < b.start
+}
+
+// ReadYourWrites returns every read that does not observe a previous write of its own session to the same key
+func (h *History) ReadYourWrites() []Violation {
+	h.RLock()
+	defer h.RUnlock()
+	w := newWrites(h.operations)
+	result := make([]Violation, 0)
+	for _, ops := range bySession(checkable(h.operations)) {
+		last := make(map[int]*operation)
+		for _, o := range ops {
+			if o.input != nil {
+				if !o.indeterminate() {
+					last[o.key] = o
+				}
+				continue
+			}
+			own, exists := last[o.key]
+			if !exists {
+				continue
+			}
+			if read, ok := w.of(o); ok && precedes(read, own) {
+				result = append(result, violation("read-your-writes", read, own, o))
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// MonotonicReads returns every read that observes an older write than a previous read of its own session to the same key
+func (h *History) MonotonicReads() []Violation {
+	h.RLock()
+	defer h.RUnlock()
+	w := newWrites(h.operations)
+	result := make([]Violation, 0)
+	for _, ops := range bySession(checkable(h.operations)) {
+		last := make(map[int]*operation)
+		for _, o := range ops {
+			if o.input != nil {
+				continue
+			}
+			read, ok := w.of(o)
+			if !ok {
+				continue
+			}
+			if prev, exists := last[o.key]; exists {
+				seen, _ := w.of(prev)
+				if precedes(read, seen) {
+					result = append(result, violation("monotonic-reads", read, seen, prev, o))
+					continue
+				}
+			}
+			last[o.key] = o
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// CausallyConsistent checks the causal order made of session order and reads-from relation,
+// and returns every read that observes a value not written, a write from its causal future,
+// or a write overwritten in its causal past
+func (h *History) CausallyConsistent() []Violation {
+	h.RLock()
+	defer h.RUnlock()
+	w := newWrites(h.operations)
+	result := make([]Violation, 0)
+	ops := checkable(h.operations)
+
+	// session index and position of every operation
+	ss := bySession(ops)
+	index := make(map[ID]int)
+	pos := make(map[*operation]int)
+	for s, ops := range ss {
+		index[s] = len(index)
+		for i, o := range ops {
+			pos[o] = i
+		}
+	}
+
+	// causal successors of every operation and number of its predecessors not yet ordered
+	successors := make(map[*operation][]*operation)
+	degree := make(map[*operation]int)
+	for _, ops := range ss {
+		for i, o := range ops {
+			if i > 0 {
+				successors[ops[i-1]] = append(successors[ops[i-1]], o)
+				degree[o]++
+			}
+			if o.input != nil {
+				continue
+			}
+			read, ok := w.of(o)
+			if !ok {
+				result = append(result, violation("thin-air-read", o))
+			} else if read != nil {
+				successors[read] = append(successors[read], o)
+				degree[o]++
+			}
+		}
+	}
+	queue := make([]*operation, 0)
+	for _, o := range ops {
+		if degree[o] == 0 {
+			queue = append(queue, o)
+		}
+	}
+
+	// vector clock of every operation in topological order,
+	// vc[o][index[s]] is the last position of session s in causal past of o
+	vc := make(map[*operation][]int)
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		clock := make([]int, len(index))
+		for i := range clock {
+			clock[i] = -1
+		}
+		if p := pos[o]; p > 0 {
+			copy(clock, vc[ss[o.Client][p-1]])
+		}
+		if o.input == nil {
+			if read, _ := w.of(o); read != nil {
+				for i, c := range vc[read] {
+					clock[i] = Max(clock[i], c)
+				}
+			}
+		}
+		clock[index[o.Client]] = pos[o]
+		vc[o] = clock
+		for _, s := range successors[o] {
+			degree[s]--
+			if degree[s] == 0 {
+				queue = append(queue, s)
+			}
+		}
+	}
+	before := func(a, b *operation) bool {
+		return vc[b][index[a.Client]] >= pos[a]
+	}
+
+	for _, o := range ops {
+		if _, ordered := vc[o]; !ordered {
+			// causal order is cyclic, o reads from its causal future or follows such read
+			if o.input == nil {
+				read, _ := w.of(o)
+				result = append(result, violation("cyclic-causality", read, o))
+			}
+			continue
+		}
+		if o.input != nil {
+			continue
+		}
+		read, ok := w.of(o)
+		if !ok {
+			continue
+		}
+		for _, write := range w[o.key] {
+			// indeterminate write may take effect after o
+			if write == read || write.indeterminate() || !before(write, o) {
+				continue
+			}
+			if read == nil || before(read, write) {
+				result = append(result, violation("causal", read, write, o))
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// violation of anomaly made of given operations, nil for initial write is skipped
+func violation(anomaly string, ops ...*operation) Violation {
+	v := Violation{Anomaly: anomaly}
+	for _, o := range ops {
+		if o != nil {
+			v.Key = o.key
+			v.Operations = append(v.Operations, o)
+		}
+	}
+	sort.Sort(byTime(v.Operations))
+	return v
+}
@@ -0,0 +1,169 @@
+package paxi
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// client returns record of operation issued by client session c
+func client(c int) OpRecord {
+	return OpRecord{Client: ID(strconv.Itoa(c))}
+}
+
+func anomalies(v []Violation) []string {
+	a := make([]string, len(v))
+	for i := range v {
+		a[i] = v[i].Anomaly
+	}
+	return a
+}
+
+func TestStaleRead(t *testing.T) {
+	// session 2 reads initial value after write of session 1 completed
+	h := NewHistory()
+	h.Add(1, 1, nil, 0, 10, client(1))
+	h.Add(1, nil, 0, 20, 30, client(2))
+	h.Add(1, nil, 1, 40, 50, client(2))
+
+	if v := h.LinearizableExact(); len(v) != 1 {
+		t.Errorf("expected linearizability violation, got %v", v)
+	}
+	if v := h.SequentiallyConsistent(); len(v) != 0 {
+		t.Errorf("unexpected sequential violation %v", v)
+	}
+	if v := h.ReadYourWrites(); len(v) != 0 {
+		t.Errorf("unexpected read-your-writes violation %v", v)
+	}
+	if v := h.MonotonicReads(); len(v) != 0 {
+		t.Errorf("unexpected monotonic reads violation %v", v)
+	}
+	if v := h.CausallyConsistent(); len(v) != 0 {
+		t.Errorf("unexpected causal violation %v", v)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	// session 1 does not read its own write
+	h := NewHistory()
+	h.Add(1, 1, nil, 0, 10, client(1))
+	h.Add(1, nil, 0, 20, 30, client(1))
+
+	v := h.ReadYourWrites()
+	if len(v) != 1 || len(v[0].Operations) != 2 {
+		t.Errorf("expected read-your-writes violation of 2 operations, got %v", v)
+	}
+	if v := h.SequentiallyConsistent(); len(v) != 1 {
+		t.Errorf("expected sequential violation, got %v", v)
+	}
+	if v := h.CausallyConsistent(); len(v) != 1 || v[0].Anomaly != "causal" {
+		t.Errorf("expected causal violation, got %v", anomalies(v))
+	}
+}
+
+func TestMonotonicReads(t *testing.T) {
+	// session 2 reads 2 and then older value 1
+	h := NewHistory()
+	h.Add(1, 1, nil, 0, 10, client(1))
+	h.Add(1, 2, nil, 20, 30, client(1))
+	h.Add(1, nil, 2, 40, 50, client(2))
+	h.Add(1, nil, 1, 60, 70, client(2))
+
+	v := h.MonotonicReads()
+	if len(v) != 1 || len(v[0].Operations) != 4 {
+		t.Errorf("expected monotonic reads violation of 4 operations, got %v", v)
+	}
+	if v := h.ReadYourWrites(); len(v) != 0 {
+		t.Errorf("unexpected read-your-writes violation %v", v)
+	}
+	if v := h.SequentiallyConsistent(); len(v) != 1 || len(v[0].Operations) != 4 {
+		t.Errorf("expected minimal sequential violation of 4 operations, got %v", v)
+	}
+	if v := h.CausallyConsistent(); len(v) != 1 {
+		t.Errorf("expected causal violation, got %v", v)
+	}
+}
+
+func TestCausal(t *testing.T) {
+	// session 3 reads y=2 which causally depends on x=1, but then reads initial x
+	const x, y = 1, 2
+	h := NewHistory()
+	h.Add(x, 1, nil, 0, 10, client(1))
+	h.Add(x, nil, 1, 20, 30, client(2))
+	h.Add(y, 2, nil, 40, 50, client(2))
+	h.Add(y, nil, 2, 60, 70, client(3))
+	h.Add(x, nil, 0, 80, 90, client(3))
+
+	v := h.CausallyConsistent()
+	if len(v) != 1 || v[0].Key != x || len(v[0].Operations) != 2 {
+		t.Errorf("expected causal violation of key x, got %v", v)
+	}
+	if v := h.SequentiallyConsistent(); len(v) != 0 {
+		t.Errorf("unexpected per-key sequential violation %v", v)
+	}
+	if v := append(h.ReadYourWrites(), h.MonotonicReads()...); len(v) != 0 {
+		t.Errorf("unexpected session violation %v", v)
+	}
+
+	// read from causal future
+	h = NewHistory()
+	h.Add(x, nil, 1, 0, 10, client(1))
+	h.Add(y, 2, nil, 20, 30, client(1))
+	h.Add(y, nil, 2, 0, 10, client(2))
+	h.Add(x, 1, nil, 20, 30, client(2))
+	if v := h.CausallyConsistent(); len(v) != 2 || v[0].Anomaly != "cyclic-causality" {
+		t.Errorf("expected cyclic causality, got %v", v)
+	}
+
+	// read of value never written
+	h = NewHistory()
+	h.Add(x, nil, 3, 0, 10, client(1))
+	if v := h.CausallyConsistent(); len(v) != 1 || v[0].Anomaly != "thin-air-read" {
+		t.Errorf("expected thin-air read, got %v", v)
+	}
+}
+
+func TestIndeterminate(t *testing.T) {
+	timeout := OpRecord{Client: "1", Err: "timeout"}
+	h := NewHistory()
+	// write of 2 times out but takes effect
+	h.Add(1, 1, nil, 0, 10, client(1))
+	h.Add(1, 2, nil, 20, math.MaxInt64, timeout)
+	h.Add(1, nil, 1, 30, 40, client(1))
+	h.Add(1, nil, 2, 50, 60, client(2))
+	h.Add(1, nil, 0, 70, math.MaxInt64, timeout)
+
+	// write of 2 times out and never takes effect
+	h.Add(2, 1, nil, 0, 10, client(1))
+	h.Add(2, 2, nil, 20, math.MaxInt64, timeout)
+	h.Add(2, nil, 1, 30, 40, client(1))
+	h.Add(2, nil, 1, 50, 60, client(2))
+
+	if n := h.Linearizable(); n != 0 {
+		t.Errorf("heuristic checker found %d anomalies", n)
+	}
+	if v := h.LinearizableExact(); len(v) != 0 {
+		t.Errorf("unexpected linearizability violation %v", v)
+	}
+	if v := h.SequentiallyConsistent(); len(v) != 0 {
+		t.Errorf("unexpected sequential violation %v", v)
+	}
+	if v := append(h.ReadYourWrites(), h.MonotonicReads()...); len(v) != 0 {
+		t.Errorf("unexpected session violation %v", v)
+	}
+	if v := h.CausallyConsistent(); len(v) != 0 {
+		t.Errorf("unexpected causal violation %v", v)
+	}
+
+	// write of 2 is observed before older value
+	h.Add(3, 1, nil, 0, 10, client(1))
+	h.Add(3, 2, nil, 20, math.MaxInt64, timeout)
+	h.Add(3, nil, 2, 30, 40, client(2))
+	h.Add(3, nil, 1, 50, 60, client(2))
+	if v := h.LinearizableExact(); len(v) != 1 || v[0].Key != 3 {
+		t.Errorf("expected linearizability violation of key 3, got %v", v)
+	}
+	if v := h.MonotonicReads(); len(v) != 1 || v[0].Key != 3 {
+		t.Errorf("expected monotonic reads violation of key 3, got %v", v)
+	}
+}
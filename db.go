@@ -6,8 +6,12 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+
+	"github.com/ailidani/paxi/log"
 )
 
 // Key type of the key-value database
@@ -61,32 +65,183 @@ func (c Command) Hash() string {
 	return hashb64
 }
 
+// ErrCompacted is returned when reading a version that is garbage collected or never kept
+var ErrCompacted = errors.New("version is compacted")
+
+// ErrNotExecuted is returned when reading as of a slot the replica has not executed yet
+var ErrNotExecuted = errors.New("slot is not executed yet")
+
 // Database defines a database interface
 // TODO replace with more general StateMachine interface
 type Database interface {
 	Execute(Command) Value
+	ExecuteAt(int, Command) Value
 	History(Key) []Value
 	Get(Key) Value
+	GetAt(Key, int) (Value, error)
 	Put(Key, Value)
+	Slot() int
+	Watch(from, to Key) *Watcher
+}
+
+// version is a value of key written at log slot
+type version struct {
+	slot  int
+	value Value
 }
 
 // Database implements a multi-version key-value datastore as the StateMachine
 type database struct {
 	sync.RWMutex
 	data         map[Key]Value
-	version      int
+	version      map[Key]int // slot of the latest write per key
 	multiversion bool
-	history      map[Key][]Value
+	horizon      int // number of slots of versions kept, keep all if 0
+	history      map[Key][]version
+
+	slot     int          // gap-free executed slot, every slot up to it is executed
+	ahead    map[int]bool // slots executed out of order after slot
+	floor    int          // lowest slot that versions are kept since
+	applied  int          // number of commands applied
+	sessions *sessions
+	watchers *watchers
+	events   map[int]Event // events of slots not published yet
+	released int           // highest slot that events are published up to
+
+	storage  Storage
+	snapshot int // number of logged writes between snapshots
+	logged   int // number of writes logged since last snapshot
 }
 
-// NewDatabase returns database that impelements Database interface
+// NewDatabase returns in-memory database that impelements Database interface
 func NewDatabase() Database {
-	return &database{
+	return newDatabase(new(memory))
+}
+
+func newDatabase(s Storage) *database {
+	d := &database{
 		data:         make(map[Key]Value),
-		version:      0,
+		version:      make(map[Key]int),
 		multiversion: config.MultiVersion,
-		history:      make(map[Key][]Value),
+		horizon:      config.VersionHorizon,
+		history:      make(map[Key][]version),
+		slot:         -1,
+		ahead:        make(map[int]bool),
+		floor:        -1,
+		sessions:     newSessions(config.SessionTimeout),
+		watchers:     newWatchers(),
+		events:       make(map[int]Event),
+		released:     -1,
+		storage:      s,
+	}
+	if _, ok := s.(*memory); !ok {
+		d.snapshot = config.SnapshotSize
+	}
+	return d
+}
+
+// OpenDatabase returns database of node id backed by storage engine from config,
+// and recovers its state if storage is persistent
+func OpenDatabase(id ID) (Database, error) {
+	s, err := NewStorage(id)
+	if err != nil {
+		return nil, err
+	}
+	d := newDatabase(s)
+	return d, d.recover()
+}
+
+// recover restores state from the latest snapshot and replays records logged after it
+func (d *database) recover() error {
+	snapshot, records, err := d.storage.Load()
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	if snapshot != nil {
+		d.slot = snapshot.Slot
+		for _, s := range snapshot.Ahead {
+			d.ahead[s] = true
+		}
+		d.applied = snapshot.Applied
+		for k, v := range snapshot.Data {
+			d.data[k] = v
+		}
+		for k, s := range snapshot.Version {
+			d.version[k] = s
+		}
+		for id, s := range snapshot.Sessions {
+			e := s
+			d.sessions.table[id] = &e
+		}
 	}
+	for _, r := range records {
+		d.applied = r.Applied
+		d.put(r.Command.Key, r.Command.Value, r.Slot)
+		for d.slot < r.Executed {
+			delete(d.ahead, d.slot+1)
+			d.slot++
+		}
+		d.advance(r.Slot)
+		d.sessions.update(r.Command, r.Reply, r.Slot)
+	}
+	// history of versions is not persistent, older versions are lost
+	d.floor = d.slot
+	d.released = d.slot
+	if d.multiversion {
+		for k, v := range d.data {
+			d.history[k] = []version{{d.version[k], v}}
+		}
+	}
+	if d.applied > 0 {
+		log.Infof("database recovered %d applied commands up to slot %d", d.applied, d.slot)
+	}
+	return nil
+}
+
+// persist logs applied write to storage and takes a snapshot periodically.
+// Reads and no-op slots are not logged, those after the last write are executed again after recovery.
+func (d *database) persist(slot int, c Command, reply Value) error {
+	err := d.storage.Append(Record{
+		Slot:     slot,
+		Executed: d.slot,
+		Applied:  d.applied,
+		Command:  c,
+		Reply:    reply,
+	})
+	if err != nil {
+		return err
+	}
+	d.logged++
+	if d.snapshot > 0 && d.logged >= d.snapshot {
+		s := Snapshot{
+			Slot:     d.slot,
+			Ahead:    make([]int, 0, len(d.ahead)),
+			Applied:  d.applied,
+			Data:     d.data,
+			Version:  d.version,
+			Sessions: make(map[ID]session),
+		}
+		for slot := range d.ahead {
+			s.Ahead = append(s.Ahead, slot)
+		}
+		for id, e := range d.sessions.table {
+			s.Sessions[id] = e.copy()
+		}
+		if err := d.storage.Save(s); err != nil {
+			return err
+		}
+		d.logged = 0
+	}
+	return nil
+}
+
+// Close closes storage of database
+func (d *database) Close() error {
+	d.Lock()
+	defer d.Unlock()
+	return d.storage.Close()
 }
 
 /*
@@ -112,59 +267,200 @@ func (d *database) Execute(c interface{}) interface{} {
 }
 */
 
-// Execute executes a command agaist database
+// Execute executes a command agaist database at the slot after the gap-free executed slot
 func (d *database) Execute(c Command) Value {
+	return d.ExecuteAt(-1, c)
+}
+
+// ExecuteAt executes a command agaist database at log slot.
+// Slots are numbered from 0 and each is executed once, possibly out of order, an empty command executes a no-op slot.
+// A retried command is not executed again, the cached reply of its client session is returned instead
+func (d *database) ExecuteAt(slot int, c Command) Value {
 	d.Lock()
 	defer d.Unlock()
 
+	if slot < 0 {
+		if v, ok := d.sessions.duplicate(c); ok {
+			return v
+		}
+		slot = d.slot + 1
+	} else if slot <= d.slot || d.ahead[slot] {
+		// executed before, e.g. replayed by the protocol after recovery
+		v, _ := d.sessions.duplicate(c)
+		return v
+	}
+	d.advance(slot)
+	v := d.apply(slot, c)
+	d.release()
+	return v
+}
+
+// advance marks slot executed and moves the gap-free executed slot over executed slots after it,
+// idle sessions expire as the gap-free executed slot moves
+func (d *database) advance(slot int) {
+	if slot <= d.slot {
+		return
+	}
+	d.ahead[slot] = true
+	for d.ahead[d.slot+1] {
+		delete(d.ahead, d.slot+1)
+		d.slot++
+	}
+	d.sessions.expire(d.slot)
+}
+
+// release publishes events in slot order up to the gap-free executed slot
+func (d *database) release() {
+	for ; d.released < d.slot; d.released++ {
+		if e, exists := d.events[d.released+1]; exists {
+			delete(d.events, d.released+1)
+			d.watchers.publish(e)
+		}
+	}
+}
+
+// apply applies command at slot
+func (d *database) apply(slot int, c Command) Value {
+	if c.Empty() {
+		return nil
+	}
+	if v, ok := d.sessions.duplicate(c); ok {
+		return v
+	}
+
+	d.applied++
+
 	// get previous value
 	v := d.data[c.Key]
 
 	// writes new value
-	d.put(c.Key, c.Value)
+	d.put(c.Key, c.Value, slot)
+
+	d.sessions.update(c, v, slot)
+	if c.IsWrite() {
+		if err := d.persist(slot, c, v); err != nil {
+			// the write is not durable, the replica stops before replying it
+			log.Fatalf("storage error of slot %d: %v", slot, err)
+		}
+	}
+
+	if c.IsWrite() {
+		d.events[slot] = Event{Slot: slot, Command: c}
+	}
 
 	return v
 }
 
-// Get gets the current value and version of given key
+// Get gets the current value of given key
 func (d *database) Get(k Key) Value {
 	d.RLock()
 	defer d.RUnlock()
 	return d.data[k]
 }
 
-func (d *database) put(k Key, v Value) {
-	if v != nil {
+// GetAt gets the value of given key as of log slot, i.e. written by the latest write at or before slot
+func (d *database) GetAt(k Key, slot int) (Value, error) {
+	d.RLock()
+	defer d.RUnlock()
+	if slot > d.slot {
+		return nil, ErrNotExecuted
+	}
+	if slot < d.floor || d.horizon > 0 && slot < d.slot-d.horizon {
+		return nil, ErrCompacted
+	}
+	s, exists := d.version[k]
+	if !exists {
+		return nil, nil
+	}
+	if slot >= s {
+		return d.data[k], nil
+	}
+	if !d.multiversion {
+		return nil, ErrCompacted
+	}
+	h := d.history[k]
+	i := sort.Search(len(h), func(i int) bool { return h[i].slot > slot })
+	if i == 0 {
+		return nil, nil
+	}
+	return h[i-1].value, nil
+}
+
+// put writes value of key at slot, a write of slot lower than the current version only adds an older version
+func (d *database) put(k Key, v Value, slot int) {
+	if v == nil {
+		return
+	}
+	if s, exists := d.version[k]; !exists || slot >= s {
 		d.data[k] = v
-		d.version++
-		if d.multiversion {
-			if d.history[k] == nil {
-				d.history[k] = make([]Value, 0)
-			}
-			d.history[k] = append(d.history[k], v)
-		}
+		d.version[k] = slot
+	}
+	if d.multiversion {
+		h := d.history[k]
+		i := sort.Search(len(h), func(i int) bool { return h[i].slot > slot })
+		h = append(h, version{})
+		copy(h[i+1:], h[i:])
+		h[i] = version{slot, v}
+		d.history[k] = h
+		d.compact(k)
+	}
+}
+
+// compact garbage collects versions of key k older than horizon slots,
+// the newest version before the horizon is kept to serve reads at the horizon
+func (d *database) compact(k Key) {
+	if d.horizon <= 0 {
+		return
+	}
+	h := d.history[k]
+	i := sort.Search(len(h), func(i int) bool { return h[i].slot > d.slot-d.horizon })
+	if i > 1 {
+		d.history[k] = append(h[:0], h[i-1:]...)
 	}
 }
 
-// Put puts a new value of given key
+// Put puts a new value of given key at the next slot
 func (d *database) Put(k Key, v Value) {
 	d.Lock()
 	defer d.Unlock()
-	d.put(k, v)
+	d.applied++
+	slot := d.slot + 1
+	d.put(k, v, slot)
+	d.advance(slot)
+	if err := d.persist(slot, Command{Key: k, Value: v}, nil); err != nil {
+		// the write is not durable, the replica stops before it returns
+		log.Fatalf("storage error of slot %d: %v", slot, err)
+	}
 }
 
-// Version returns current version of given key
+// Slot returns the gap-free executed slot, every slot up to it is executed, including those recovered from storage
+func (d *database) Slot() int {
+	d.RLock()
+	defer d.RUnlock()
+	return d.slot
+}
+
+// Version returns the slot of the latest write of given key
 func (d *database) Version(k Key) int {
 	d.RLock()
 	defer d.RUnlock()
-	return d.version
+	return d.version[k]
 }
 
 // History returns entire vlue history in order
 func (d *database) History(k Key) []Value {
 	d.RLock()
 	defer d.RUnlock()
-	return d.history[k]
+	values := make([]Value, 0, len(d.history[k]))
+	for _, v := range d.history[k] {
+		values = append(values, v.value)
+	}
+	return values
+}
+
+// Watch returns a watcher of write commands executed on keys in range [from, to]
+func (d *database) Watch(from, to Key) *Watcher {
+	return d.watchers.add(from, to)
 }
 
 func (d *database) String() string {
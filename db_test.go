@@ -0,0 +1,181 @@
+package paxi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDatabaseDuplicate(t *testing.T) {
+	db := NewDatabase()
+	put := Command{Key: 1, Value: Value("a"), ClientID: "1.1", CommandID: 1}
+	db.Execute(put)
+
+	put2 := Command{Key: 1, Value: Value("b"), ClientID: "1.1", CommandID: 2}
+	if v := db.Execute(put2); !bytes.Equal(v, Value("a")) {
+		t.Errorf("Execute(%v) = %s, want a", put2, v)
+	}
+
+	// retry of the last command returns cached reply without executing again
+	if v := db.Execute(put2); !bytes.Equal(v, Value("a")) {
+		t.Errorf("retry Execute(%v) = %s, want cached a", put2, v)
+	}
+	// stale retry must not overwrite newer value
+	db.Execute(put)
+	if v := db.Get(1); !bytes.Equal(v, Value("b")) {
+		t.Errorf("Get(1) = %s, want b", v)
+	}
+
+	// other client with the same command id is not a duplicate
+	put3 := Command{Key: 1, Value: Value("c"), ClientID: "1.2", CommandID: 2}
+	if v := db.Execute(put3); !bytes.Equal(v, Value("b")) {
+		t.Errorf("Execute(%v) = %s, want b", put3, v)
+	}
+	if v := db.Get(1); !bytes.Equal(v, Value("c")) {
+		t.Errorf("Get(1) = %s, want c", v)
+	}
+}
+
+func TestDatabaseDuplicateOutOfOrder(t *testing.T) {
+	// follower applies command 2 of session ahead of command 1
+	db := NewDatabase()
+	a := Command{Key: 1, Value: Value("a"), ClientID: "1.1", CommandID: 1}
+	b := Command{Key: 2, Value: Value("b"), ClientID: "1.1", CommandID: 2}
+	db.ExecuteAt(1, b)
+	db.ExecuteAt(0, a)
+	if v := db.Get(1); !bytes.Equal(v, Value("a")) {
+		t.Errorf("Get(1) = %s, command 1 applied after command 2 is skipped", v)
+	}
+
+	// both commands are duplicates once applied
+	db.ExecuteAt(2, Command{Key: 1, Value: Value("x"), ClientID: "1.1", CommandID: 1})
+	db.ExecuteAt(3, Command{Key: 2, Value: Value("x"), ClientID: "1.1", CommandID: 2})
+	if v := db.Get(1); !bytes.Equal(v, Value("a")) {
+		t.Errorf("Get(1) = %s, retry of command 1 applied again", v)
+	}
+	if v := db.Get(2); !bytes.Equal(v, Value("b")) {
+		t.Errorf("Get(2) = %s, retry of command 2 applied again", v)
+	}
+	if e := db.(*database).sessions.table["1.1"]; e.CID != 2 || len(e.Applied) != 0 {
+		t.Errorf("session has gap-free command id %d and %d applied above it, want 2 and 0", e.CID, len(e.Applied))
+	}
+}
+
+func TestSessionExpire(t *testing.T) {
+	s := newSessions(2)
+	s.update(Command{Key: 1, ClientID: "1.1", CommandID: 1}, nil, 1)
+	s.update(Command{Key: 1, ClientID: "1.2", CommandID: 1}, nil, 3)
+	// command executed ahead of the gap-free slot keeps its session
+	s.update(Command{Key: 1, ClientID: "1.3", CommandID: 1}, nil, 9)
+	s.expire(3)
+	s.expire(5)
+	if _, exists := s.table["1.1"]; exists {
+		t.Error("idle session 1.1 did not expire")
+	}
+	if _, exists := s.table["1.2"]; !exists {
+		t.Error("active session 1.2 expired")
+	}
+	if _, exists := s.table["1.3"]; !exists {
+		t.Error("session 1.3 used after gap-free slot expired")
+	}
+}
+
+func TestDatabaseSessionExpire(t *testing.T) {
+	timeout := config.SessionTimeout
+	config.SessionTimeout = 4
+	defer func() { config.SessionTimeout = timeout }()
+
+	// replicas executing the same slots in different orders expire the same sessions
+	order := [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, {0, 9, 1, 2, 8, 3, 4, 5, 6, 7}}
+	for _, slots := range order {
+		db := NewDatabase().(*database)
+		for _, s := range slots {
+			switch s {
+			case 0:
+				db.ExecuteAt(s, Command{Key: 1, Value: Value("a"), ClientID: "1.1", CommandID: 1})
+			case 8:
+				db.ExecuteAt(s, Command{Key: 1, Value: Value("b"), ClientID: "1.2", CommandID: 1})
+			case 9:
+				db.ExecuteAt(s, Command{Key: 1, Value: Value("c"), ClientID: "1.3", CommandID: 1})
+			default:
+				db.ExecuteAt(s, Command{})
+			}
+		}
+		if _, exists := db.sessions.table["1.1"]; exists {
+			t.Errorf("idle session 1.1 did not expire in order %v", slots)
+		}
+		if len(db.sessions.table) != 2 {
+			t.Errorf("sessions %v in order %v, want 1.2 and 1.3", db.sessions.table, slots)
+		}
+	}
+}
+
+func TestDatabaseGetAt(t *testing.T) {
+	config.MultiVersion = true
+	config.VersionHorizon = 10
+	defer func() {
+		config.MultiVersion = false
+		config.VersionHorizon = 0
+	}()
+	db := NewDatabase()
+	for s := 0; s <= 31; s++ {
+		switch s % 5 {
+		case 0:
+			db.ExecuteAt(s, Command{Key: 1, Value: Value{byte(s)}})
+		case 1:
+			db.ExecuteAt(s, Command{Key: 2, Value: Value{byte(s - 1)}})
+		default:
+			db.ExecuteAt(s, Command{})
+		}
+	}
+
+	tests := []struct {
+		slot  int
+		value Value
+		err   error
+	}{
+		{30, Value{30}, nil},
+		{29, Value{25}, nil},
+		{24, Value{20}, nil},
+		{21, Value{20}, nil},
+		{20, nil, ErrCompacted},
+		{32, nil, ErrNotExecuted},
+	}
+	for _, test := range tests {
+		v, err := db.GetAt(1, test.slot)
+		if err != test.err || !bytes.Equal(v, test.value) {
+			t.Errorf("GetAt(1, %d) = %v, %v, want %v, %v", test.slot, v, err, test.value, test.err)
+		}
+	}
+	if v, err := db.GetAt(3, 25); v != nil || err != nil {
+		t.Errorf("GetAt(3, 25) = %v, %v, want nil", v, err)
+	}
+	if h := db.History(1); len(h) != 3 {
+		t.Errorf("History(1) = %v, want 3 versions within horizon", h)
+	}
+}
+
+func TestDatabaseOutOfOrder(t *testing.T) {
+	config.MultiVersion = true
+	defer func() { config.MultiVersion = false }()
+	db := NewDatabase()
+	db.ExecuteAt(0, Command{Key: 1, Value: Value("a")})
+	db.ExecuteAt(2, Command{Key: 1, Value: Value("c")})
+
+	if _, err := db.GetAt(1, 2); err != ErrNotExecuted {
+		t.Errorf("GetAt(1, 2) before slot 1 is executed returns %v, want %v", err, ErrNotExecuted)
+	}
+	if v, err := db.GetAt(1, 0); err != nil || !bytes.Equal(v, Value("a")) {
+		t.Errorf("GetAt(1, 0) = %s, %v, want a", v, err)
+	}
+
+	// lower slot executed later does not overwrite current version
+	db.ExecuteAt(1, Command{Key: 1, Value: Value("b")})
+	if v := db.Get(1); !bytes.Equal(v, Value("c")) {
+		t.Errorf("Get(1) = %s, want c", v)
+	}
+	for slot, value := range []string{"a", "b", "c"} {
+		if v, err := db.GetAt(1, slot); err != nil || !bytes.Equal(v, Value(value)) {
+			t.Errorf("GetAt(1, %d) = %s, %v, want %s", slot, v, err, value)
+		}
+	}
+}
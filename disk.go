@@ -0,0 +1,190 @@
+package paxi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ailidani/paxi/log"
+)
+
+// disk is an append-only storage engine.
+// Records are appended to a log file as checksummed frames, and the log is replaced by
+// a snapshot file on Save. A torn frame at the tail of log after crash is discarded on Load.
+//
+// frame = | length uint32 | crc32 uint32 | gob encoded Record |
+type disk struct {
+	dir  string
+	file *os.File // log file opened for append
+	sync int      // fsync log after every sync records, never if 0
+	n    int      // records appended since last fsync
+}
+
+const (
+	diskLog      = "log"
+	diskSnapshot = "snapshot"
+)
+
+func newDisk(path string, id ID, sync int) (*disk, error) {
+	dir := filepath.Join(path, string(id))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	d := &disk{
+		dir:  dir,
+		sync: sync,
+	}
+	return d, d.open()
+}
+
+func (d *disk) open() error {
+	file, err := os.OpenFile(filepath.Join(d.dir, diskLog), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	d.file = file
+	return nil
+}
+
+// Append writes record as one frame, fsync log once every d.sync records
+func (d *disk) Append(r Record) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	if err := gob.NewEncoder(&buf).Encode(r); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)-8))
+	binary.LittleEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(b[8:]))
+	if _, err := d.file.Write(b); err != nil {
+		return err
+	}
+	if d.sync > 0 {
+		d.n++
+		if d.n >= d.sync {
+			d.n = 0
+			return d.file.Sync()
+		}
+	}
+	return nil
+}
+
+// Save atomically replaces snapshot file and empties log
+func (d *disk) Save(s Snapshot) error {
+	tmp := filepath.Join(d.dir, diskSnapshot+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := gob.NewEncoder(w).Encode(s); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, diskSnapshot)); err != nil {
+		return err
+	}
+	// rename is durable only once the directory is synced, log must not be emptied before
+	if err := syncDir(d.dir); err != nil {
+		return err
+	}
+	// records already in snapshot are skipped by Load if we crash before truncate
+	d.n = 0
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	return d.file.Sync()
+}
+
+// syncDir flushes entries of directory dir to disk
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads snapshot and all intact frames of log, and truncates log after the last intact frame
+func (d *disk) Load() (*Snapshot, []Record, error) {
+	var snapshot *Snapshot
+	file, err := os.Open(filepath.Join(d.dir, diskSnapshot))
+	if err == nil {
+		snapshot = new(Snapshot)
+		err = gob.NewDecoder(bufio.NewReader(file)).Decode(snapshot)
+		file.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	records := make([]Record, 0)
+	r := bufio.NewReader(d.file)
+	offset := int64(0)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		n := binary.LittleEndian.Uint32(header[0:4])
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(b) != binary.LittleEndian.Uint32(header[4:8]) {
+			break
+		}
+		var record Record
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&record); err != nil {
+			break
+		}
+		if snapshot == nil || record.Applied > snapshot.Applied {
+			records = append(records, record)
+		}
+		offset += int64(8 + n)
+	}
+
+	info, err := d.file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() > offset {
+		log.Warningf("storage %s discards %d bytes of torn log", d.dir, info.Size()-offset)
+		if err := d.file.Truncate(offset); err != nil {
+			return nil, nil, err
+		}
+	}
+	return snapshot, records, nil
+}
+
+func (d *disk) Close() error {
+	if err := d.file.Sync(); err != nil {
+		log.Error(err)
+	}
+	return d.file.Close()
+}
@@ -0,0 +1,754 @@
+package epaxos
+
+import (
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/lib"
+	"github.com/ailidani/paxi/log"
+)
+
+var timeout = flag.Duration("recovery_timeout", time.Second, "EPaxos waiting time on an uncommitted dependency before recovering it")
+
+// Status of instance
+type Status int8
+
+// status of each instance in order of progress
+const (
+	None Status = iota
+	PreAccepted
+	Accepted
+	Committed
+	Executed
+)
+
+// id of instance in the space of its leader replica
+type id struct {
+	replica paxi.ID
+	slot    int
+}
+
+// instance of command
+type instance struct {
+	ballot  paxi.Ballot // highest ballot promised
+	vballot paxi.Ballot // ballot of accepted attributes
+	status  Status
+	command paxi.Command
+	seq     int
+	deps    map[paxi.ID]int
+
+	request   paxi.Command     // command proposed by this replica
+	reply     func(paxi.Reply) // replies to client of request
+	quorum    *paxi.Quorum
+	changed   bool           // any PreAcceptReply differs from proposed attributes
+	recovery  []PrepareReply // replies of ongoing recovery
+	timestamp time.Time
+}
+
+// EPaxos instance of leaderless Egalitarian Paxos,
+// every replica leads its own instances and commits in one round trip on fast quorum
+// when there is no concurrent interfering command, otherwise in two round trips.
+// Committed instances are executed in strongly connected components of dependency graph,
+// and removed from log once every replica executed them.
+type EPaxos struct {
+	paxi.Node
+
+	log   map[id]*instance
+	slot  int                          // highest slot of own instances
+	write map[paxi.Key]map[paxi.ID]int // highest slot of write per key per replica
+	any   map[paxi.Key]map[paxi.ID]int // highest slot of any command per key per replica
+	seq   map[paxi.Key]int             // highest seq per key
+
+	committed   map[id]bool                 // committed but not yet executed
+	executed    map[paxi.ID]int             // every instance of replica up to slot is executed
+	checkpoints map[paxi.ID]map[paxi.ID]int // executed slots announced by every replica
+	pruned      map[paxi.ID]int             // every instance of replica up to slot is executed everywhere and removed from log
+
+	FastQuorum func(*paxi.Quorum) bool
+	SlowQuorum func(*paxi.Quorum) bool
+}
+
+// NewEPaxos creates new epaxos instance
+func NewEPaxos(n paxi.Node, options ...func(*EPaxos)) *EPaxos {
+	p := &EPaxos{
+		Node:        n,
+		log:         make(map[id]*instance, paxi.GetConfig().BufferSize),
+		slot:        -1,
+		write:       make(map[paxi.Key]map[paxi.ID]int),
+		any:         make(map[paxi.Key]map[paxi.ID]int),
+		seq:         make(map[paxi.Key]int),
+		committed:   make(map[id]bool),
+		executed:    make(map[paxi.ID]int),
+		checkpoints: make(map[paxi.ID]map[paxi.ID]int),
+		pruned:      make(map[paxi.ID]int),
+		FastQuorum:  func(q *paxi.Quorum) bool { return q.FastQuorum() },
+		SlowQuorum:  func(q *paxi.Quorum) bool { return q.Majority() },
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+// isPruned returns true if instance i is executed by every replica and removed from log
+func (p *EPaxos) isPruned(i id) bool {
+	s, exists := p.pruned[i.replica]
+	return exists && i.slot <= s
+}
+
+// instance returns the instance of id, creates an empty one if not exists
+func (p *EPaxos) instance(i id) *instance {
+	inst, exists := p.log[i]
+	if !exists {
+		inst = &instance{timestamp: time.Now()}
+		p.log[i] = inst
+	}
+	return inst
+}
+
+// attributes returns seq and deps of command c against all interfering commands known locally
+func (p *EPaxos) attributes(c paxi.Command, self id) (int, map[paxi.ID]int) {
+	last := p.any[c.Key]
+	if c.IsRead() {
+		// reads only interfere with writes
+		last = p.write[c.Key]
+	}
+	deps := make(map[paxi.ID]int, len(last))
+	for r, s := range last {
+		if r == self.replica && s >= self.slot {
+			continue
+		}
+		deps[r] = s
+	}
+	return p.seq[c.Key] + 1, deps
+}
+
+// record updates the interfering commands with instance i
+func (p *EPaxos) record(i id, inst *instance) {
+	c := inst.command
+	if c.Empty() {
+		return
+	}
+	if p.any[c.Key] == nil {
+		p.any[c.Key] = make(map[paxi.ID]int)
+		p.write[c.Key] = make(map[paxi.ID]int)
+	}
+	if s, exists := p.any[c.Key][i.replica]; !exists || i.slot > s {
+		p.any[c.Key][i.replica] = i.slot
+	}
+	if c.IsWrite() {
+		if s, exists := p.write[c.Key][i.replica]; !exists || i.slot > s {
+			p.write[c.Key][i.replica] = i.slot
+		}
+	}
+	p.seq[c.Key] = paxi.Max(p.seq[c.Key], inst.seq)
+}
+
+// HandleRequest starts phase 1 pre-accept of request in a new instance
+func (p *EPaxos) HandleRequest(r paxi.Request) {
+	p.propose(r.Command, r.Reply)
+}
+
+// propose starts command c in next own instance and calls reply once executed
+func (p *EPaxos) propose(c paxi.Command, reply func(paxi.Reply)) {
+	p.slot++
+	i := id{p.ID(), p.slot}
+	seq, deps := p.attributes(c, i)
+	inst := &instance{
+		ballot:    paxi.NewBallot(0, p.ID()),
+		vballot:   paxi.NewBallot(0, p.ID()),
+		status:    PreAccepted,
+		command:   c,
+		seq:       seq,
+		deps:      deps,
+		request:   c,
+		reply:     reply,
+		quorum:    paxi.NewQuorum(),
+		timestamp: time.Now(),
+	}
+	p.log[i] = inst
+	p.record(i, inst)
+	inst.quorum.ACK(p.ID())
+	p.Broadcast(PreAccept{
+		Ballot:  inst.ballot,
+		Replica: i.replica,
+		Slot:    i.slot,
+		Command: inst.command,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+	})
+}
+
+// HandlePreAccept handles PreAccept message
+func (p *EPaxos) HandlePreAccept(m PreAccept) {
+	i := id{m.Replica, m.Slot}
+	if p.isPruned(i) {
+		return
+	}
+	inst := p.instance(i)
+	if inst.status >= Committed {
+		return
+	}
+	if m.Ballot < inst.ballot {
+		p.Send(m.Ballot.ID(), PreAcceptReply{
+			Ballot:  inst.ballot,
+			ID:      p.ID(),
+			Replica: m.Replica,
+			Slot:    m.Slot,
+		})
+		return
+	}
+
+	seq, deps := p.attributes(m.Command, i)
+	inst.ballot = m.Ballot
+	inst.vballot = m.Ballot
+	inst.status = PreAccepted
+	inst.command = m.Command
+	inst.seq = paxi.Max(seq, m.Seq)
+	inst.deps = union(deps, m.Deps)
+	inst.timestamp = time.Now()
+	p.record(i, inst)
+
+	p.Send(m.Ballot.ID(), PreAcceptReply{
+		Ballot:  m.Ballot,
+		ID:      p.ID(),
+		Replica: m.Replica,
+		Slot:    m.Slot,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+		OK:      true,
+	})
+}
+
+// HandlePreAcceptReply handles PreAcceptReply message
+func (p *EPaxos) HandlePreAcceptReply(m PreAcceptReply) {
+	i := id{m.Replica, m.Slot}
+	inst, exists := p.log[i]
+	if !exists || inst.status != PreAccepted || inst.quorum == nil {
+		return
+	}
+	// preempted by recovery of other replica
+	if !m.OK {
+		if m.Ballot > inst.ballot {
+			inst.ballot = m.Ballot
+			inst.quorum = nil
+		}
+		return
+	}
+	if m.Ballot != inst.ballot {
+		return
+	}
+
+	if m.Seq != inst.seq || !equal(m.Deps, inst.deps) {
+		inst.changed = true
+		inst.seq = paxi.Max(inst.seq, m.Seq)
+		inst.deps = union(inst.deps, m.Deps)
+	}
+	inst.quorum.ACK(m.ID)
+
+	// fast path is only for the initial ballot of instance leader
+	if !inst.changed && inst.ballot.N() == 0 && p.FastQuorum(inst.quorum) {
+		p.commit(i, inst)
+	} else if inst.changed && p.SlowQuorum(inst.quorum) {
+		p.accept(i, inst)
+	}
+}
+
+// accept starts phase 2 of slow path
+func (p *EPaxos) accept(i id, inst *instance) {
+	inst.status = Accepted
+	inst.vballot = inst.ballot
+	inst.quorum = paxi.NewQuorum()
+	inst.quorum.ACK(p.ID())
+	inst.timestamp = time.Now()
+	p.record(i, inst)
+	p.Broadcast(Accept{
+		Ballot:  inst.ballot,
+		Replica: i.replica,
+		Slot:    i.slot,
+		Command: inst.command,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+	})
+}
+
+// HandleAccept handles Accept message
+func (p *EPaxos) HandleAccept(m Accept) {
+	i := id{m.Replica, m.Slot}
+	if p.isPruned(i) {
+		return
+	}
+	inst := p.instance(i)
+	if inst.status >= Committed {
+		return
+	}
+	if m.Ballot < inst.ballot {
+		p.Send(m.Ballot.ID(), AcceptReply{
+			Ballot:  inst.ballot,
+			ID:      p.ID(),
+			Replica: m.Replica,
+			Slot:    m.Slot,
+		})
+		return
+	}
+
+	inst.ballot = m.Ballot
+	inst.vballot = m.Ballot
+	inst.status = Accepted
+	inst.command = m.Command
+	inst.seq = m.Seq
+	inst.deps = m.Deps
+	inst.timestamp = time.Now()
+	p.record(i, inst)
+
+	p.Send(m.Ballot.ID(), AcceptReply{
+		Ballot:  m.Ballot,
+		ID:      p.ID(),
+		Replica: m.Replica,
+		Slot:    m.Slot,
+		OK:      true,
+	})
+}
+
+// HandleAcceptReply handles AcceptReply message
+func (p *EPaxos) HandleAcceptReply(m AcceptReply) {
+	i := id{m.Replica, m.Slot}
+	inst, exists := p.log[i]
+	if !exists || inst.status != Accepted || inst.quorum == nil {
+		return
+	}
+	if !m.OK {
+		if m.Ballot > inst.ballot {
+			inst.ballot = m.Ballot
+			inst.quorum = nil
+		}
+		return
+	}
+	if m.Ballot != inst.ballot {
+		return
+	}
+
+	inst.quorum.ACK(m.ID)
+	if p.SlowQuorum(inst.quorum) {
+		p.commit(i, inst)
+	}
+}
+
+// commit broadcasts the chosen attributes of instance and tries to execute
+func (p *EPaxos) commit(i id, inst *instance) {
+	inst.quorum = nil
+	p.Broadcast(Commit{
+		Replica: i.replica,
+		Slot:    i.slot,
+		Command: inst.command,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+	})
+	p.committing(i, inst)
+	p.exec()
+}
+
+// HandleCommit handles Commit message
+func (p *EPaxos) HandleCommit(m Commit) {
+	i := id{m.Replica, m.Slot}
+	if p.isPruned(i) {
+		return
+	}
+	inst := p.instance(i)
+	if inst.status >= Committed {
+		return
+	}
+	inst.command = m.Command
+	inst.seq = m.Seq
+	inst.deps = m.Deps
+	inst.quorum = nil
+	inst.recovery = nil
+	p.committing(i, inst)
+	p.exec()
+}
+
+func (p *EPaxos) committing(i id, inst *instance) {
+	inst.status = Committed
+	p.record(i, inst)
+	p.committed[i] = true
+	// instance recovered with a different command, propose the request again
+	if inst.reply != nil && !inst.command.Equal(inst.request) {
+		reply := inst.reply
+		inst.reply = nil
+		p.propose(inst.request, reply)
+	}
+}
+
+// exec executes every committed instance whose dependencies are all committed
+func (p *EPaxos) exec() {
+	pending := make([]id, 0, len(p.committed))
+	for i := range p.committed {
+		pending = append(pending, i)
+	}
+	for _, i := range pending {
+		if p.committed[i] {
+			p.execute(i)
+		}
+	}
+}
+
+// Tick is called periodically, it retries execution of blocked instances so that they are recovered after timeout,
+// and announces executed instances to other replicas to prune the log
+func (p *EPaxos) Tick() {
+	if len(p.committed) > 0 {
+		p.exec()
+	}
+	executed := make(map[paxi.ID]int, len(p.executed))
+	for r, s := range p.executed {
+		executed[r] = s
+	}
+	p.HandleCheckpoint(Checkpoint{ID: p.ID(), Executed: executed})
+	p.Broadcast(Checkpoint{ID: p.ID(), Executed: executed})
+}
+
+// HandleCheckpoint handles Checkpoint message, and prunes instances executed by every replica
+func (p *EPaxos) HandleCheckpoint(m Checkpoint) {
+	p.checkpoints[m.ID] = m.Executed
+	for r := range p.executed {
+		floor := p.executed[r]
+		for _, n := range paxi.GetConfig().IDs() {
+			s, exists := p.checkpoints[n][r]
+			if !exists {
+				floor = -1
+				break
+			}
+			floor = paxi.Min(floor, s)
+		}
+		s, exists := p.pruned[r]
+		if !exists {
+			s = -1
+		}
+		if floor <= s {
+			continue
+		}
+		for ; s < floor; s++ {
+			delete(p.log, id{r, s + 1})
+		}
+		p.pruned[r] = floor
+	}
+}
+
+// execute builds the dependency graph reachable from instance i, returns false if any instance in graph is not committed.
+// Strongly connected components are executed in reverse topological order, and instances within one component by seq.
+func (p *EPaxos) execute(i id) bool {
+	g := lib.NewGraph()
+	g.Add(i)
+	stack := []id{i}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for r, s := range p.log[v].deps {
+			w := id{r, s}
+			if p.isPruned(w) {
+				continue
+			}
+			inst := p.instance(w)
+			if inst.status < Committed {
+				p.blocked(w, inst)
+				return false
+			}
+			if inst.status == Executed {
+				continue
+			}
+			if !g.Has(w) {
+				stack = append(stack, w)
+			}
+			g.AddEdge(v, w)
+		}
+	}
+
+	for _, component := range g.SCC() {
+		sort.Slice(component, func(a, b int) bool {
+			x, y := component[a].(id), component[b].(id)
+			if p.log[x].seq != p.log[y].seq {
+				return p.log[x].seq < p.log[y].seq
+			}
+			if x.replica != y.replica {
+				return x.replica < y.replica
+			}
+			return x.slot < y.slot
+		})
+		for _, v := range component {
+			p.apply(v.(id))
+		}
+	}
+	return true
+}
+
+// apply executes command of instance i in database and replies to its request
+func (p *EPaxos) apply(i id) {
+	inst := p.log[i]
+	log.Debugf("Replica %s execute [i=%s.%d, cmd=%v]", p.ID(), i.replica, i.slot, inst.command)
+	var value paxi.Value
+	if !inst.command.Empty() {
+		value = p.Execute(inst.command)
+	}
+	inst.status = Executed
+	delete(p.committed, i)
+	s, exists := p.executed[i.replica]
+	if !exists {
+		s = -1
+	}
+	for next, ok := p.log[id{i.replica, s + 1}]; ok && next.status == Executed; next, ok = p.log[id{i.replica, s + 1}] {
+		s++
+	}
+	if s >= 0 {
+		p.executed[i.replica] = s
+	}
+	if inst.reply != nil {
+		inst.reply(paxi.Reply{
+			Command:   inst.command,
+			Value:     value,
+			Timestamp: time.Now().Unix(),
+		})
+		inst.reply = nil
+	}
+}
+
+// blocked starts recovery of instance that blocks execution for longer than timeout
+func (p *EPaxos) blocked(i id, inst *instance) {
+	if time.Since(inst.timestamp) < *timeout {
+		return
+	}
+	p.recover(i, inst)
+}
+
+// recover takes over instance i with a higher ballot
+func (p *EPaxos) recover(i id, inst *instance) {
+	log.Debugf("Replica %s recover instance %s.%d", p.ID(), i.replica, i.slot)
+	inst.ballot.Next(p.ID())
+	inst.quorum = paxi.NewQuorum()
+	inst.quorum.ACK(p.ID())
+	inst.timestamp = time.Now()
+	inst.recovery = []PrepareReply{p.prepareReply(i, inst)}
+	p.Broadcast(Prepare{
+		Ballot:  inst.ballot,
+		Replica: i.replica,
+		Slot:    i.slot,
+	})
+}
+
+func (p *EPaxos) prepareReply(i id, inst *instance) PrepareReply {
+	m := PrepareReply{
+		Ballot:  inst.ballot,
+		ID:      p.ID(),
+		Replica: i.replica,
+		Slot:    i.slot,
+		OK:      true,
+		Status:  inst.status,
+		VBallot: inst.vballot,
+		Command: inst.command,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+	}
+	if inst.status == PreAccepted {
+		m.Conflicts = p.conflicts(i, inst.command)
+	}
+	return m
+}
+
+// conflicts returns the highest slot per replica of committed instances interfering with command c of instance i
+// that do not depend on i. Such an instance was committed by a quorum that did not pre-accept i before it.
+func (p *EPaxos) conflicts(i id, c paxi.Command) map[paxi.ID]int {
+	conflicts := make(map[paxi.ID]int)
+	if c.Empty() {
+		return conflicts
+	}
+	for j, inst := range p.log {
+		if j == i || inst.status < Committed || inst.command.Empty() || inst.command.Key != c.Key {
+			continue
+		}
+		if c.IsRead() && inst.command.IsRead() {
+			continue
+		}
+		if s, exists := inst.deps[i.replica]; exists && s >= i.slot {
+			continue
+		}
+		if s, exists := conflicts[j.replica]; !exists || j.slot > s {
+			conflicts[j.replica] = j.slot
+		}
+	}
+	return conflicts
+}
+
+// HandlePrepare handles Prepare message
+func (p *EPaxos) HandlePrepare(m Prepare) {
+	i := id{m.Replica, m.Slot}
+	if p.isPruned(i) {
+		// every replica executed it, nobody is blocked on it
+		return
+	}
+	inst := p.instance(i)
+	if m.Ballot < inst.ballot {
+		p.Send(m.Ballot.ID(), PrepareReply{
+			Ballot:  inst.ballot,
+			ID:      p.ID(),
+			Replica: m.Replica,
+			Slot:    m.Slot,
+		})
+		return
+	}
+	inst.ballot = m.Ballot
+	inst.recovery = nil
+	if inst.status < Committed {
+		inst.quorum = nil
+	}
+	p.Send(m.Ballot.ID(), p.prepareReply(i, inst))
+}
+
+// HandlePrepareReply handles PrepareReply message
+func (p *EPaxos) HandlePrepareReply(m PrepareReply) {
+	i := id{m.Replica, m.Slot}
+	inst, exists := p.log[i]
+	if !exists || inst.recovery == nil || inst.status >= Committed {
+		return
+	}
+	if !m.OK {
+		if m.Ballot > inst.ballot {
+			inst.ballot = m.Ballot
+			inst.recovery = nil
+			inst.quorum = nil
+		}
+		return
+	}
+	if m.Ballot != inst.ballot {
+		return
+	}
+
+	inst.recovery = append(inst.recovery, m)
+	inst.quorum.ACK(m.ID)
+	if !p.SlowQuorum(inst.quorum) {
+		return
+	}
+
+	replies := inst.recovery
+	inst.recovery = nil
+
+	// (1) committed in any replica
+	for _, r := range replies {
+		if r.Status >= Committed {
+			inst.command, inst.seq, inst.deps = r.Command, r.Seq, r.Deps
+			p.commit(i, inst)
+			return
+		}
+	}
+
+	// (2) accepted attributes of highest ballot
+	var accepted *PrepareReply
+	for k, r := range replies {
+		if r.Status == Accepted && (accepted == nil || r.VBallot > accepted.VBallot) {
+			accepted = &replies[k]
+		}
+	}
+	if accepted != nil {
+		inst.command, inst.seq, inst.deps = accepted.Command, accepted.Seq, accepted.Deps
+		p.accept(i, inst)
+		return
+	}
+
+	// (3) identical pre-accepted attributes of initial ballot by at least ⌊(F+1)/2⌋ replicas other than the leader,
+	// the number of them any majority sees if the instance is committed on fast quorum of F+⌊(F+1)/2⌋ replicas.
+	// The attributes may be committed on fast path only if no replica knows a committed interfering instance
+	// that neither depends on the instance nor is in its dependencies.
+	var preaccepted []PrepareReply
+	for _, r := range replies {
+		if r.Status == PreAccepted {
+			preaccepted = append(preaccepted, r)
+		}
+	}
+	f := (paxi.GetConfig().N() - 1) / 2
+	for _, r := range preaccepted {
+		if r.VBallot.N() != 0 || r.ID == i.replica {
+			continue
+		}
+		n := 0
+		for _, o := range preaccepted {
+			if o.VBallot.N() == 0 && o.ID != i.replica && o.Command.Equal(r.Command) && o.Seq == r.Seq && equal(o.Deps, r.Deps) {
+				n++
+			}
+		}
+		if n >= (f+1)/2 && !conflict(r.Deps, replies) {
+			inst.command, inst.seq, inst.deps = r.Command, r.Seq, r.Deps
+			p.accept(i, inst)
+			return
+		}
+	}
+
+	// (4) restart phase 1 with any pre-accepted command on slow path, or (5) commit no-op
+	inst.command = paxi.Command{}
+	if len(preaccepted) > 0 {
+		inst.command = preaccepted[0].Command
+	}
+	if inst.command.Empty() {
+		inst.seq, inst.deps = 0, make(map[paxi.ID]int)
+		p.accept(i, inst)
+		return
+	}
+	inst.seq, inst.deps = p.attributes(inst.command, i)
+	for _, r := range preaccepted {
+		inst.seq = paxi.Max(inst.seq, r.Seq)
+		inst.deps = union(inst.deps, r.Deps)
+	}
+	inst.status = PreAccepted
+	inst.vballot = inst.ballot
+	inst.changed = true
+	inst.quorum = paxi.NewQuorum()
+	inst.quorum.ACK(p.ID())
+	inst.timestamp = time.Now()
+	p.record(i, inst)
+	p.Broadcast(PreAccept{
+		Ballot:  inst.ballot,
+		Replica: i.replica,
+		Slot:    i.slot,
+		Command: inst.command,
+		Seq:     inst.seq,
+		Deps:    inst.deps,
+	})
+}
+
+// conflict returns true if any committed instance reported in replies is not in deps
+func conflict(deps map[paxi.ID]int, replies []PrepareReply) bool {
+	for _, r := range replies {
+		for replica, slot := range r.Conflicts {
+			if s, exists := deps[replica]; !exists || slot > s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// union returns the union of two dependencies with higher slot of each replica
+func union(a, b map[paxi.ID]int) map[paxi.ID]int {
+	u := make(map[paxi.ID]int, len(a)+len(b))
+	for r, s := range a {
+		u[r] = s
+	}
+	for r, s := range b {
+		if x, exists := u[r]; !exists || s > x {
+			u[r] = s
+		}
+	}
+	return u
+}
+
+func equal(a, b map[paxi.ID]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for r, s := range a {
+		if x, exists := b[r]; !exists || x != s {
+			return false
+		}
+	}
+	return true
+}
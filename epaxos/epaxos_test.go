@@ -0,0 +1,162 @@
+package epaxos
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+// node records the order of executed commands per key
+type node struct {
+	*paxitest.Node
+
+	sync.Mutex
+	executed map[paxi.Key][]paxi.Command
+}
+
+func (n *node) Execute(c paxi.Command) paxi.Value {
+	n.Lock()
+	n.executed[c.Key] = append(n.executed[c.Key], c)
+	n.Unlock()
+	return n.Node.Execute(c)
+}
+
+// writes returns the executed writes of key k
+func (n *node) writes(k paxi.Key) []paxi.Command {
+	n.Lock()
+	defer n.Unlock()
+	w := make([]paxi.Command, 0)
+	for _, c := range n.executed[k] {
+		if c.IsWrite() {
+			w = append(w, c)
+		}
+	}
+	return w
+}
+
+func (n *node) size() int {
+	n.Lock()
+	defer n.Unlock()
+	s := 0
+	for _, l := range n.executed {
+		s += len(l)
+	}
+	return s
+}
+
+var ids = []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}
+
+func cluster(t *testing.T) (map[paxi.ID]*Replica, map[paxi.ID]*node) {
+	flag.Set("recovery_timeout", "20ms")
+	paxitest.Configure(t, ids...)
+	replicas := make(map[paxi.ID]*Replica)
+	nodes := make(map[paxi.ID]*node)
+	for _, id := range ids {
+		nodes[id] = &node{Node: paxitest.NewNode(id), executed: make(map[paxi.Key][]paxi.Command)}
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+	return replicas, nodes
+}
+
+// wait waits until replicas executed n commands
+func wait(t *testing.T, nodes map[paxi.ID]*node, n int, replicas ...paxi.ID) {
+	for _, id := range replicas {
+		paxitest.Eventually(t, func() error {
+			if s := nodes[id].size(); s < n {
+				return fmt.Errorf("replica %s executed %d of %d commands", id, s, n)
+			}
+			return nil
+		})
+	}
+}
+
+func TestEPaxos(t *testing.T) {
+	replicas, nodes := cluster(t)
+	clients := make([]*paxitest.Node, 0)
+	for _, id := range ids {
+		clients = append(clients, nodes[id].Node)
+	}
+	paxitest.Linearizable(t, clients, 10, 50)
+
+	wait(t, nodes, 500, ids...)
+	for k := 0; k < 3; k++ {
+		expected := nodes[ids[0]].writes(paxi.Key(k))
+		for _, id := range ids[1:] {
+			if !reflect.DeepEqual(nodes[id].writes(paxi.Key(k)), expected) {
+				t.Errorf("replica %s executed writes of key %d in different order", id, k)
+			}
+		}
+	}
+
+	// instances executed by every replica are pruned
+	for _, id := range ids {
+		paxitest.Eventually(t, func() error {
+			var n int
+			nodes[id].Do(func() { n = len(replicas[id].log) })
+			if n > 0 {
+				return fmt.Errorf("replica %s keeps %d executed instances", id, n)
+			}
+			return nil
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	replicas, nodes := cluster(t)
+
+	// replica 1.1 crashes after its PreAccept reaches 1.2 only
+	nodes["1.1"].Lost = func(to paxi.ID, m interface{}) bool {
+		_, ok := m.(PreAccept)
+		return !ok || to != "1.2"
+	}
+	a := paxi.Command{Key: 0, Value: paxi.Value("a"), ClientID: "a", CommandID: 1}
+	nodes["1.1"].Do(func() {
+		replicas["1.1"].propose(a, func(paxi.Reply) {})
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	// conflicting command of 1.2 depends on a and blocks until a is recovered by timer
+	b := paxi.Command{Key: 0, Value: paxi.Value("b"), ClientID: "b", CommandID: 1}
+	nodes["1.2"].Call(t, b)
+
+	wait(t, nodes, 2, ids[1:]...)
+	for _, id := range ids[1:] {
+		if !reflect.DeepEqual(nodes[id].writes(0), nodes["1.2"].writes(0)) {
+			t.Errorf("replica %s executed writes of key 0 in different order", id)
+		}
+	}
+}
+
+func TestRecoverFastCommit(t *testing.T) {
+	replicas, nodes := cluster(t)
+
+	// replica 1.1 commits a on fast path with pre-accepts of 1.2 and 1.3, executes it and crashes before commit is sent
+	nodes["1.1"].Lost = func(to paxi.ID, m interface{}) bool {
+		_, ok := m.(PreAccept)
+		return !ok || to != "1.2" && to != "1.3"
+	}
+	a := paxi.Command{Key: 0, Value: paxi.Value("a"), ClientID: "a", CommandID: 1}
+	nodes["1.1"].Do(func() {
+		replicas["1.1"].propose(a, func(paxi.Reply) {})
+	})
+	wait(t, nodes, 1, "1.1")
+	nodes["1.1"].Fail(true)
+	nodes["1.2"].Fail(true)
+
+	// recovery quorum of 1.3, 1.4 and 1.5 sees only one pre-accept of a, conflicting b must still execute after a
+	b := paxi.Command{Key: 0, Value: paxi.Value("b"), ClientID: "b", CommandID: 1}
+	nodes["1.4"].Call(t, b)
+	wait(t, nodes, 2, "1.3", "1.4", "1.5")
+	for _, id := range []paxi.ID{"1.3", "1.4", "1.5"} {
+		if w := nodes[id].writes(0); !w[0].Equal(a) || !w[1].Equal(b) {
+			t.Errorf("replica %s executed %v, fast committed a must execute first", id, w)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package epaxos
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+)
+
+func init() {
+	gob.Register(PreAccept{})
+	gob.Register(PreAcceptReply{})
+	gob.Register(Accept{})
+	gob.Register(AcceptReply{})
+	gob.Register(Commit{})
+	gob.Register(Prepare{})
+	gob.Register(PrepareReply{})
+	gob.Register(Checkpoint{})
+}
+
+// PreAccept message proposes command with its attributes in phase 1
+type PreAccept struct {
+	Ballot  paxi.Ballot
+	Replica paxi.ID // instance leader
+	Slot    int
+	Command paxi.Command
+	Seq     int
+	Deps    map[paxi.ID]int
+}
+
+func (m PreAccept) String() string {
+	return fmt.Sprintf("PreAccept {b=%v i=%s.%d cmd=%v seq=%d deps=%v}", m.Ballot, m.Replica, m.Slot, m.Command, m.Seq, m.Deps)
+}
+
+// PreAcceptReply message returns attributes updated by interfering commands of acceptor
+type PreAcceptReply struct {
+	Ballot  paxi.Ballot
+	ID      paxi.ID // from node id
+	Replica paxi.ID
+	Slot    int
+	Seq     int
+	Deps    map[paxi.ID]int
+	OK      bool
+}
+
+func (m PreAcceptReply) String() string {
+	return fmt.Sprintf("PreAcceptReply {b=%v id=%s i=%s.%d seq=%d deps=%v ok=%t}", m.Ballot, m.ID, m.Replica, m.Slot, m.Seq, m.Deps, m.OK)
+}
+
+// Accept message of slow path phase 2
+type Accept struct {
+	Ballot  paxi.Ballot
+	Replica paxi.ID
+	Slot    int
+	Command paxi.Command
+	Seq     int
+	Deps    map[paxi.ID]int
+}
+
+func (m Accept) String() string {
+	return fmt.Sprintf("Accept {b=%v i=%s.%d cmd=%v seq=%d deps=%v}", m.Ballot, m.Replica, m.Slot, m.Command, m.Seq, m.Deps)
+}
+
+// AcceptReply message
+type AcceptReply struct {
+	Ballot  paxi.Ballot
+	ID      paxi.ID // from node id
+	Replica paxi.ID
+	Slot    int
+	OK      bool
+}
+
+func (m AcceptReply) String() string {
+	return fmt.Sprintf("AcceptReply {b=%v id=%s i=%s.%d ok=%t}", m.Ballot, m.ID, m.Replica, m.Slot, m.OK)
+}
+
+// Commit message
+type Commit struct {
+	Replica paxi.ID
+	Slot    int
+	Command paxi.Command
+	Seq     int
+	Deps    map[paxi.ID]int
+}
+
+func (m Commit) String() string {
+	return fmt.Sprintf("Commit {i=%s.%d cmd=%v seq=%d deps=%v}", m.Replica, m.Slot, m.Command, m.Seq, m.Deps)
+}
+
+// Prepare message starts recovery of an instance with higher ballot
+type Prepare struct {
+	Ballot  paxi.Ballot
+	Replica paxi.ID
+	Slot    int
+}
+
+func (m Prepare) String() string {
+	return fmt.Sprintf("Prepare {b=%v i=%s.%d}", m.Ballot, m.Replica, m.Slot)
+}
+
+// PrepareReply message returns the instance state of acceptor
+type PrepareReply struct {
+	Ballot  paxi.Ballot
+	ID      paxi.ID // from node id
+	Replica paxi.ID
+	Slot    int
+	OK      bool
+	Status  Status
+	VBallot paxi.Ballot // ballot of the accepted attributes
+	Command paxi.Command
+	Seq     int
+	Deps    map[paxi.ID]int
+
+	// Conflicts is the highest slot per replica of committed instances interfering with Command that do not depend on it
+	Conflicts map[paxi.ID]int
+}
+
+func (m PrepareReply) String() string {
+	return fmt.Sprintf("PrepareReply {b=%v id=%s i=%s.%d ok=%t status=%d vb=%v cmd=%v seq=%d deps=%v}",
+		m.Ballot, m.ID, m.Replica, m.Slot, m.OK, m.Status, m.VBallot, m.Command, m.Seq, m.Deps)
+}
+
+// Checkpoint message announces the instances executed by replica,
+// instances executed by every replica are pruned from the log
+type Checkpoint struct {
+	ID       paxi.ID         // from node id
+	Executed map[paxi.ID]int // every instance of replica up to slot is executed
+}
+
+func (m Checkpoint) String() string {
+	return fmt.Sprintf("Checkpoint {id=%s executed=%v}", m.ID, m.Executed)
+}
@@ -0,0 +1,59 @@
+package epaxos
+
+import (
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// tick is the timer message delivered to replica itself
+type tick struct{}
+
+// Replica for one EPaxos instance, every replica accepts requests from clients
+type Replica struct {
+	paxi.Node
+	*EPaxos
+}
+
+// NewReplica generates new EPaxos replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new EPaxos replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.EPaxos = NewEPaxos(r)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(tick{}, r.handleTick)
+	r.Register(PreAccept{}, r.HandlePreAccept)
+	r.Register(PreAcceptReply{}, r.HandlePreAcceptReply)
+	r.Register(Accept{}, r.HandleAccept)
+	r.Register(AcceptReply{}, r.HandleAcceptReply)
+	r.Register(Commit{}, r.HandleCommit)
+	r.Register(Prepare{}, r.HandlePrepare)
+	r.Register(PrepareReply{}, r.HandlePrepareReply)
+	r.Register(Checkpoint{}, r.HandleCheckpoint)
+	return r
+}
+
+// Run starts the timer and runs the node, blocked instances are checked twice per recovery timeout
+func (r *Replica) Run() {
+	go func() {
+		for range time.Tick(*timeout / 2) {
+			r.Local(tick{})
+		}
+	}()
+	r.Node.Run()
+}
+
+func (r *Replica) handleTick(tick) {
+	r.EPaxos.Tick()
+}
+
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+	r.EPaxos.HandleRequest(m)
+}
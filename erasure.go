@@ -1,12 +1,18 @@
 package paxi
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/klauspost/reedsolomon"
 )
 
+// Splitvalue encodes value into X data shards and K parity shards,
+// an empty value is encoded as X+K empty shards
 func Splitvalue(value Value, X int, K int) (nums [][]byte, err error) {
+	if len(value) == 0 {
+		return make([][]byte, X+K), nil
+	}
 	// 数据分10片和校验3片 create an encoder with 10 data shards and 3 parity shards
 	enc, err := reedsolomon.New(X, K)
 	if err != nil {
@@ -26,14 +32,34 @@ func Splitvalue(value Value, X int, K int) (nums [][]byte, err error) {
 	return shards, nil
 }
 
-func recoverdata(data [][]byte) (nums [][]byte, err error) {
-	enc, err := reedsolomon.New(3, 2)
+// Recovervalue reconstructs the original value of given size from X data and K parity shards,
+// missing shards are nil and any X of them are enough
+func Recovervalue(shards [][]byte, X int, K int, size int) (Value, error) {
+	if size == 0 {
+		return Value{}, nil
+	}
+	data, err := recoverdata(shards, X, K)
+	if err != nil {
+		return nil, err
+	}
+	value := make(Value, 0, len(data[0])*X)
+	for _, shard := range data[:X] {
+		value = append(value, shard...)
+	}
+	if len(value) < size {
+		return nil, errors.New("shards are shorter than value size")
+	}
+	return value[:size], nil
+}
+
+func recoverdata(data [][]byte, X int, K int) (nums [][]byte, err error) {
+	enc, err := reedsolomon.New(X, K)
 	if err != nil {
 		return nil, fmt.Errorf("创建数据分片和校验分片失败: %s", err.Error())
 	}
 	err = enc.Reconstruct(data)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to encode data:", err)
+		return nil, fmt.Errorf("Failed to encode data: %v", err)
 	}
 	return data, nil
 }
@@ -0,0 +1,36 @@
+package paxi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestErasureRecover(t *testing.T) {
+	layouts := []struct{ x, k int }{{3, 2}, {2, 1}, {1, 2}}
+	value := Value("the quick brown fox jumps over the lazy dog")
+	for _, l := range layouts {
+		shards, err := Splitvalue(value, l.x, l.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// lose every parity worth of shards
+		for i := 0; i < l.k; i++ {
+			shards[i] = nil
+		}
+		v, err := Recovervalue(shards, l.x, l.k, len(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(v, value) {
+			t.Errorf("Recovervalue with %d+%d shards = %s, want %s", l.x, l.k, v, value)
+		}
+	}
+
+	shards, err := Splitvalue(Value{}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Recovervalue(shards, 3, 2, 0); err != nil || len(v) != 0 {
+		t.Errorf("Recovervalue of empty value = %v, %v", v, err)
+	}
+}
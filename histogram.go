@@ -0,0 +1,348 @@
+package paxi
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// default range and precision of latency histogram
+const (
+	HistogramLowest  = time.Microsecond
+	HistogramHighest = time.Hour
+	HistogramDigits  = 3
+)
+
+// Histogram is a high dynamic range (HDR) histogram of latencies, as described in http://hdrhistogram.org.
+// It takes fixed memory for given range, and keeps given number of significant decimal digits of every value,
+// so any percentile is accurate within relative error of 10^-digits.
+// Values are counted in buckets of powers of two, each divided linearly into sub-buckets.
+// Values out of range are counted at the bound, but min and max are exact.
+type Histogram struct {
+	sync.RWMutex
+
+	lowest  int64 // lowest discernible value in nanoseconds
+	highest int64 // highest trackable value in nanoseconds
+	digits  int   // significant decimal digits
+
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int
+	subBucketMask               int64
+
+	counts []int64
+	total  int64
+	sum    float64 // in nanoseconds
+	min    int64
+	max    int64
+}
+
+// NewHistogram creates new histogram of latencies between lowest and highest with given significant digits between 1 and 5
+func NewHistogram(lowest, highest time.Duration, digits int) *Histogram {
+	if lowest < 1 {
+		lowest = 1
+	}
+	if highest < 2*lowest {
+		highest = 2 * lowest
+	}
+	if digits < 1 {
+		digits = 1
+	}
+	if digits > 5 {
+		digits = 5
+	}
+	h := &Histogram{
+		lowest:  int64(lowest),
+		highest: int64(highest),
+		digits:  digits,
+		min:     math.MaxInt64,
+	}
+	largest := 2 * int64(math.Pow10(digits))
+	h.unitMagnitude = uint(bits.Len64(uint64(h.lowest)) - 1)
+	h.subBucketHalfCountMagnitude = uint(bits.Len64(uint64(largest-1))) - 1
+	h.subBucketHalfCount = 1 << h.subBucketHalfCountMagnitude
+	subBucketCount := int64(2 * h.subBucketHalfCount)
+	h.subBucketMask = (subBucketCount - 1) << h.unitMagnitude
+
+	// number of buckets to cover highest value
+	buckets := 1
+	for smallest := subBucketCount << h.unitMagnitude; smallest <= h.highest && smallest > 0; smallest <<= 1 {
+		buckets++
+	}
+	h.counts = make([]int64, (buckets+1)*h.subBucketHalfCount)
+	return h
+}
+
+// NewLatencyHistogram creates new histogram of default range and precision
+func NewLatencyHistogram() *Histogram {
+	return NewHistogram(HistogramLowest, HistogramHighest, HistogramDigits)
+}
+
+// index returns the index of counts for value v
+func (h *Histogram) index(v int64) int {
+	bucket := int(64-h.unitMagnitude-h.subBucketHalfCountMagnitude-1) - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	sub := int(v >> (uint(bucket) + h.unitMagnitude))
+	return (bucket+1)<<h.subBucketHalfCountMagnitude + sub - h.subBucketHalfCount
+}
+
+// lowestOf returns the lowest value counted at index i
+func (h *Histogram) lowestOf(i int) int64 {
+	bucket := i>>h.subBucketHalfCountMagnitude - 1
+	sub := i&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucket < 0 {
+		sub -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return int64(sub) << (uint(bucket) + h.unitMagnitude)
+}
+
+// highestOf returns the highest value counted at index i
+func (h *Histogram) highestOf(i int) int64 {
+	bucket := i>>h.subBucketHalfCountMagnitude - 1
+	if bucket < 0 {
+		bucket = 0
+	}
+	return h.lowestOf(i) + int64(1)<<(uint(bucket)+h.unitMagnitude) - 1
+}
+
+// Record adds one latency into histogram
+func (h *Histogram) Record(latency time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.record(int64(latency), 1)
+}
+
+func (h *Histogram) record(v int64, n int64) {
+	if v < 0 {
+		v = 0
+	}
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.total += n
+	h.sum += float64(v) * float64(n)
+	if v > h.highest {
+		v = h.highest
+	}
+	h.counts[h.index(v)] += n
+}
+
+// Merge adds all latencies recorded in o into histogram,
+// latencies of histogram with different range or precision are recorded again at the middle of their sub-buckets
+func (h *Histogram) Merge(o *Histogram) {
+	if h == o {
+		return
+	}
+	o.RLock()
+	defer o.RUnlock()
+	h.Lock()
+	defer h.Unlock()
+	if o.total == 0 {
+		return
+	}
+	same := h.lowest == o.lowest && h.highest == o.highest && h.digits == o.digits
+	for i, n := range o.counts {
+		if n == 0 {
+			continue
+		}
+		if same {
+			h.counts[i] += n
+			continue
+		}
+		v := (o.lowestOf(i) + o.highestOf(i)) / 2
+		if v > h.highest {
+			v = h.highest
+		}
+		h.counts[h.index(v)] += n
+	}
+	h.total += o.total
+	h.sum += o.sum
+	if o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// Count returns number of latencies recorded
+func (h *Histogram) Count() int {
+	h.RLock()
+	defer h.RUnlock()
+	return int(h.total)
+}
+
+// Mean returns the exact mean of latencies, zero if empty
+func (h *Histogram) Mean() time.Duration {
+	h.RLock()
+	defer h.RUnlock()
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.total))
+}
+
+// Min returns the smallest latency, zero if empty
+func (h *Histogram) Min() time.Duration {
+	h.RLock()
+	defer h.RUnlock()
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+// Max returns the largest latency, zero if empty
+func (h *Histogram) Max() time.Duration {
+	h.RLock()
+	defer h.RUnlock()
+	return time.Duration(h.max)
+}
+
+// Percentile returns the latency at or below which percentile p (0 to 100) of latencies are, zero if empty
+func (h *Histogram) Percentile(p float64) time.Duration {
+	h.RLock()
+	defer h.RUnlock()
+	return time.Duration(h.percentile(p))
+}
+
+func (h *Histogram) percentile(p float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	if p > 100 {
+		p = 100
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank >= h.total {
+		return h.max
+	}
+	var count int64
+	for i, n := range h.counts {
+		count += n
+		if count >= rank {
+			v := h.highestOf(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Stat returns the summary statistics of histogram
+func (h *Histogram) Stat() Stat {
+	h.RLock()
+	defer h.RUnlock()
+	ms := func(v int64) float64 { return float64(v) / float64(time.Millisecond) }
+	s := Stat{Size: int(h.total)}
+	if h.total == 0 {
+		return s
+	}
+	s.Mean = h.sum / float64(h.total) / float64(time.Millisecond)
+	s.Min = ms(h.min)
+	s.Max = ms(h.max)
+	s.Median = ms(h.percentile(50))
+	s.P95 = ms(h.percentile(95))
+	s.P99 = ms(h.percentile(99))
+	s.P999 = ms(h.percentile(99.9))
+	return s
+}
+
+// jsonHistogram is the serialized histogram with only non-empty counts as pairs of index and count,
+// all values are in nanoseconds
+type jsonHistogram struct {
+	Lowest  int64      `json:"lowest"`
+	Highest int64      `json:"highest"`
+	Digits  int        `json:"digits"`
+	Total   int64      `json:"total"`
+	Sum     float64    `json:"sum"`
+	Min     int64      `json:"min"`
+	Max     int64      `json:"max"`
+	Counts  [][2]int64 `json:"counts"`
+}
+
+// MarshalJSON encodes histogram
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	h.RLock()
+	defer h.RUnlock()
+	j := jsonHistogram{
+		Lowest:  h.lowest,
+		Highest: h.highest,
+		Digits:  h.digits,
+		Total:   h.total,
+		Sum:     h.sum,
+		Min:     h.min,
+		Max:     h.max,
+		Counts:  make([][2]int64, 0),
+	}
+	for i, n := range h.counts {
+		if n != 0 {
+			j.Counts = append(j.Counts, [2]int64{int64(i), n})
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes histogram
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var j jsonHistogram
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	n := NewHistogram(time.Duration(j.Lowest), time.Duration(j.Highest), j.Digits)
+	for _, c := range j.Counts {
+		if c[0] < 0 || c[0] >= int64(len(n.counts)) {
+			return errors.New("histogram index out of range")
+		}
+		n.counts[c[0]] += c[1]
+	}
+	n.total, n.sum, n.min, n.max = j.Total, j.Sum, j.Min, j.Max
+
+	h.Lock()
+	defer h.Unlock()
+	h.lowest, h.highest, h.digits = n.lowest, n.highest, n.digits
+	h.unitMagnitude = n.unitMagnitude
+	h.subBucketHalfCountMagnitude = n.subBucketHalfCountMagnitude
+	h.subBucketHalfCount = n.subBucketHalfCount
+	h.subBucketMask = n.subBucketMask
+	h.counts = n.counts
+	h.total, h.sum, h.min, h.max = n.total, n.sum, n.min, n.max
+	return nil
+}
+
+// WriteFile writes histogram in JSON format into file
+func (h *Histogram) WriteFile(path string) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// ReadHistogram reads histogram written by WriteFile
+func ReadHistogram(path string) (*Histogram, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := new(Histogram)
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,105 @@
+package paxi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// within returns true if v is within relative error of 10^-HistogramDigits from expected
+func within(v, expected time.Duration) bool {
+	return math.Abs(float64(v-expected)) <= float64(expected)/math.Pow10(HistogramDigits)
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewLatencyHistogram()
+	if s := h.Stat(); s.Size != 0 || s.Max != 0 || h.Percentile(99) != 0 {
+		t.Errorf("empty histogram has statistics %v", s)
+	}
+
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	if h.Count() != 10000 {
+		t.Errorf("histogram counts %d latencies, expected 10000", h.Count())
+	}
+	if h.Min() != time.Millisecond || h.Max() != 10*time.Second {
+		t.Errorf("histogram min %v max %v", h.Min(), h.Max())
+	}
+	if h.Mean() != 5000500*time.Microsecond {
+		t.Errorf("histogram mean %v", h.Mean())
+	}
+	for _, p := range []float64{1, 50, 95, 99, 99.9, 100} {
+		expected := time.Duration(p*100) * time.Millisecond
+		if v := h.Percentile(p); !within(v, expected) {
+			t.Errorf("p%v = %v, expected %v", p, v, expected)
+		}
+	}
+
+	// out of range
+	h.Record(2 * time.Hour)
+	if h.Max() != 2*time.Hour || h.Percentile(100) != 2*time.Hour {
+		t.Errorf("histogram max %v p100 %v", h.Max(), h.Percentile(100))
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := NewLatencyHistogram()
+	b := NewLatencyHistogram()
+	c := NewHistogram(time.Millisecond, time.Minute, 2)
+	all := NewLatencyHistogram()
+	for i := 1; i <= 3000; i++ {
+		l := time.Duration(i) * time.Millisecond
+		switch i % 3 {
+		case 0:
+			a.Record(l)
+		case 1:
+			b.Record(l)
+		case 2:
+			c.Record(l)
+		}
+		all.Record(l)
+	}
+	a.Merge(b)
+	a.Merge(c)
+	if a.Count() != 3000 || a.Min() != time.Millisecond || a.Max() != 3*time.Second {
+		t.Errorf("merged histogram %v", a.Stat())
+	}
+	for _, p := range []float64{50, 99} {
+		if v, expected := a.Percentile(p), all.Percentile(p); !within(v, expected) {
+			t.Errorf("merged p%v = %v, expected %v", p, v, expected)
+		}
+	}
+}
+
+func TestHistogramFile(t *testing.T) {
+	h := NewLatencyHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i*i) * time.Microsecond)
+	}
+
+	dir, err := ioutil.TempDir("", "histogram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "latency.json")
+	if err := h.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadHistogram(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stat() != h.Stat() {
+		t.Errorf("read histogram %v, expected %v", r.Stat(), h.Stat())
+	}
+
+	if err := json.Unmarshal([]byte(`{"lowest":1000,"highest":3600000000000,"digits":3,"counts":[[-1,1]]}`), r); err == nil {
+		t.Error("expected error of count out of range")
+	}
+}
@@ -1,15 +1,6 @@
 package paxi
 
 import (
-	"bufio"
-	"encoding/csv"
-	"errors"
-	"fmt"
-	"io"
-	"log"
-	"os"
-	"sort"
-	"strconv"
 	"sync"
 )
 
@@ -28,16 +19,22 @@ func NewHistory() *History {
 	}
 }
 
-// Add puts an operation in History
-func (h *History) Add(key int, input, output interface{}, start, end int64) {
-	h.Lock()
-	defer h.Unlock()
-	if _, exists := h.shard[key]; !exists {
-		h.shard[key] = make([]*operation, 0)
+// Add puts an operation in History, with optional record of client command and outcome
+func (h *History) Add(key int, input, output interface{}, start, end int64, record ...OpRecord) {
+	o := &operation{
+		key:    key,
+		input:  input,
+		output: output,
+		start:  start,
+		end:    end,
 	}
-	o := &operation{key, input, output, start, end}
-	h.shard[key] = append(h.shard[key], o)
-	h.operations = append(h.operations, o)
+	if len(record) > 0 {
+		o.OpRecord = record[0]
+	}
+	if input != nil {
+		o.Type = WriteOp
+	}
+	h.AddOperation(key, o)
 }
 
 // AddOperation adds the operation
@@ -51,130 +48,51 @@ func (h *History) AddOperation(key int, o *operation) {
 	h.operations = append(h.operations, o)
 }
 
-// Linearizable concurrently checks if each partition of the history is linearizable and returns the total number of anomaly reads
+// Linearizable concurrently checks if each partition of the history is linearizable and returns the total number of anomaly reads,
+// reads that failed are ignored
 func (h *History) Linearizable() int {
-	anomalies := make(chan []*operation)
-	h.RLock()
-	defer h.RUnlock()
-	for _, partition := range h.shard {
-		c := newChecker()
-		go func(p []*operation) {
-			anomalies <- c.linearizable(p)
-		}(partition)
-	}
 	sum := 0
-	for range h.shard {
-		a := <-anomalies
+	for _, a := range h.anomalies() {
 		sum += len(a)
 	}
 	return sum
 }
 
-// WriteFile writes entire operation history into file
-func (h *History) WriteFile(path string) error {
-	file, err := os.Create(path + ".csv")
-	if err != nil {
-		return err
+// anomalies concurrently checks each partition of the history and returns the anomalies of every key that is not linearizable.
+// Checker runs on copies of operations as it refines their response time, anomalies refer to operations in history.
+func (h *History) anomalies() map[int][]anomaly {
+	type result struct {
+		key       int
+		anomalies []anomaly
 	}
-	defer file.Close()
-
-	w := bufio.NewWriter(file)
+	results := make(chan result)
 	h.RLock()
 	defer h.RUnlock()
-
-	sort.Sort(byTime(h.operations))
-
-	latency := 0.0
-	throughput := 0
-	s := 1.0
-	for _, o := range h.operations {
-		key := o.key
-		start := float64(o.start) / 1000000000.0
-		end := float64(o.end) / 1000000000.0
-		fmt.Fprintf(w, "%d,%v,%v,%f,%f\n", key, o.input, o.output, start, end)
-		latency += end - start
-		throughput++
-		if end > s {
-			//fmt.Fprintf(w, "PerSecond %f %d\n", latency/float64(throughput)*1000.0, throughput)
-			latency = 0
-			throughput = 0
-			s++
-		}
-
-		// fmt.Fprintln(w, o)
-	}
-
-	// for k, ops := range h.shard {
-	// 	fmt.Fprintf(w, "key=%d\n", k)
-	// 	for _, o := range ops {
-	// 		fmt.Fprintln(w, o)
-	// 	}
-	// }
-	return w.Flush()
-}
-
-// ReadFile reads csv log file and create operations in history
-func (h *History) ReadFile(path string) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return err
+	for k, partition := range h.shard {
+		go func(k int, p []*operation) {
+			origin := make(map[*operation]*operation, len(p))
+			copies := make([]*operation, len(p))
+			for i, o := range p {
+				c := *o
+				copies[i] = &c
+				origin[&c] = o
+			}
+			anomalies := newChecker().linearizable(copies)
+			for i := range anomalies {
+				anomalies[i].read = origin[anomalies[i].read]
+				for j, o := range anomalies[i].cycle {
+					anomalies[i].cycle[j] = origin[o]
+				}
+			}
+			results <- result{k, anomalies}
+		}(k, checkable(partition))
 	}
-
-	r := csv.NewReader(file)
-
-	for {
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		if len(record) < 5 {
-			return errors.New("operation history file format error")
-		}
-
-		// get id / key
-		id, err := strconv.Atoi(record[0])
-		if err != nil {
-			return err
-		}
-		//fmt.Fprintln(id)
-		operation := new(operation)
-		operation.key, err = strconv.Atoi(record[1])
-		// get input
-		if record[2] == "null" || record[2] == "" {
-			operation.input = nil
-		} else {
-			operation.input = record[2]
-		}
-
-		// get output
-		if record[3] == "null" || record[3] == "" {
-			operation.output = nil
-		} else {
-			operation.output = record[3]
-		}
-
-		// get start time
-		start, err := strconv.ParseInt(record[4], 10, 64)
-		if err != nil {
-			log.Fatal(err)
-			return err
-		}
-		operation.start = start
-
-		// get end time
-		end, err := strconv.ParseInt(record[5], 10, 64)
-		if err != nil {
-			log.Fatal(err)
-			return err
+	anomalies := make(map[int][]anomaly)
+	for range h.shard {
+		r := <-results
+		if len(r.anomalies) > 0 {
+			anomalies[r.key] = r.anomalies
 		}
-		operation.end = end
-
-		h.AddOperation(id, operation)
 	}
-
-	return file.Close()
+	return anomalies
 }
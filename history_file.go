@@ -0,0 +1,360 @@
+package paxi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// History file format.
+// A CSV history file starts with record "#paxi-history,<version>" and a record of column names,
+// followed by one record of every operation, timestamps are in nanoseconds since start of benchmark,
+// empty input or output is nil.
+// A JSON Lines history file starts with line {"format":"paxi-history","version":<version>},
+// followed by one JSON object of every operation.
+// Files without version are read as older formats: csv of key, input, output, start, end in seconds,
+// optionally followed by client, command, type, replica, error;
+// or csv of id, key, input, output, start, end in nanoseconds where operations are partitioned by id.
+
+// HistoryVersion is version of the history file format
+const HistoryVersion = 1
+
+// history file formats
+const (
+	HistoryCSV   = "csv"
+	HistoryJSONL = "jsonl"
+)
+
+const historyMagic = "paxi-history"
+
+var historyColumns = []string{"key", "input", "output", "start", "end", "client", "command", "type", "replica", "error"}
+
+// historyHeader is the first line of a JSON Lines history file
+type historyHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// jsonOperation is one operation in JSON Lines history file
+type jsonOperation struct {
+	Key     int     `json:"key"`
+	Input   *string `json:"input"`
+	Output  *string `json:"output"`
+	Start   int64   `json:"start"`
+	End     int64   `json:"end"`
+	Client  ID      `json:"client,omitempty"`
+	Command int     `json:"command,omitempty"`
+	Type    string  `json:"type"`
+	Replica ID      `json:"replica,omitempty"`
+	Err     string  `json:"error,omitempty"`
+}
+
+// WriteFile writes entire operation history into file, in JSON Lines format if path ends with ".jsonl",
+// otherwise in csv format and ".csv" is appended to path if missing
+func (h *History) WriteFile(path string) error {
+	format := HistoryCSV
+	if strings.HasSuffix(path, "."+HistoryJSONL) {
+		format = HistoryJSONL
+	} else if !strings.HasSuffix(path, "."+HistoryCSV) {
+		path += "." + HistoryCSV
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := h.Encode(w, format); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Encode writes entire operation history in invocation order to w in given format
+func (h *History) Encode(w io.Writer, format string) error {
+	h.Lock()
+	defer h.Unlock()
+	sort.Sort(byTime(h.operations))
+
+	switch format {
+	case HistoryCSV:
+		return h.encodeCSV(w)
+	case HistoryJSONL:
+		return h.encodeJSONL(w)
+	}
+	return fmt.Errorf("unknown history format %s", format)
+}
+
+func (h *History) encodeCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"#" + historyMagic, strconv.Itoa(HistoryVersion)})
+	c.Write(historyColumns)
+	for _, o := range h.operations {
+		c.Write([]string{
+			strconv.Itoa(o.key),
+			encodeValue(o.input),
+			encodeValue(o.output),
+			strconv.FormatInt(o.start, 10),
+			strconv.FormatInt(o.end, 10),
+			string(o.Client),
+			strconv.Itoa(o.Command),
+			o.Type.String(),
+			string(o.Replica),
+			o.Err,
+		})
+	}
+	c.Flush()
+	return c.Error()
+}
+
+func encodeValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (h *History) encodeJSONL(w io.Writer) error {
+	e := json.NewEncoder(w)
+	if err := e.Encode(historyHeader{historyMagic, HistoryVersion}); err != nil {
+		return err
+	}
+	for _, o := range h.operations {
+		j := jsonOperation{
+			Key:     o.key,
+			Start:   o.start,
+			End:     o.end,
+			Client:  o.Client,
+			Command: o.Command,
+			Type:    o.Type.String(),
+			Replica: o.Replica,
+			Err:     o.Err,
+		}
+		if o.input != nil {
+			s := fmt.Sprint(o.input)
+			j.Input = &s
+		}
+		if o.output != nil {
+			s := fmt.Sprint(o.output)
+			j.Output = &s
+		}
+		if err := e.Encode(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile reads history file of any format and create operations in history
+func (h *History) ReadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return h.Decode(file)
+}
+
+// Decode reads operations in any history format from r, values of operations are read as strings
+func (h *History) Decode(r io.Reader) error {
+	b := bufio.NewReader(r)
+	first, err := b.Peek(1)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if first[0] == '{' {
+		return h.decodeJSONL(b)
+	}
+	return h.decodeCSV(b)
+}
+
+func (h *History) decodeJSONL(r io.Reader) error {
+	d := json.NewDecoder(r)
+	var header historyHeader
+	if err := d.Decode(&header); err != nil {
+		return err
+	}
+	if header.Format != historyMagic {
+		return errors.New("operation history file format error")
+	}
+	if header.Version > HistoryVersion {
+		return fmt.Errorf("unsupported history version %d", header.Version)
+	}
+	for {
+		var j jsonOperation
+		err := d.Decode(&j)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		o := &operation{
+			key:   j.Key,
+			start: j.Start,
+			end:   j.End,
+			OpRecord: OpRecord{
+				Client:  j.Client,
+				Command: j.Command,
+				Replica: j.Replica,
+				Err:     j.Err,
+			},
+		}
+		if j.Input != nil {
+			o.input = *j.Input
+		}
+		if j.Output != nil {
+			o.output = *j.Output
+		}
+		if o.Type, err = parseType(j.Type); err != nil {
+			return err
+		}
+		h.AddOperation(o.key, o)
+	}
+}
+
+func (h *History) decodeCSV(r io.Reader) error {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+
+	version := 0
+	for {
+		record, err := c.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(record[0], "#") {
+			if record[0] != "#"+historyMagic || len(record) < 2 {
+				return errors.New("operation history file format error")
+			}
+			if version, err = strconv.Atoi(record[1]); err != nil {
+				return err
+			}
+			if version > HistoryVersion {
+				return fmt.Errorf("unsupported history version %d", version)
+			}
+			// column names
+			if _, err = c.Read(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id := 0
+		var o *operation
+		switch {
+		case version > 0 && len(record) == len(historyColumns):
+			o, err = parseOperation(record, 1)
+			if err == nil {
+				id = o.key
+				err = parseRecord(record[5:], &o.OpRecord)
+			}
+		case version == 0 && len(record) == 6:
+			id, err = strconv.Atoi(record[0])
+			if err == nil {
+				o, err = parseOperation(record[1:], 1)
+			}
+		case version == 0 && (len(record) == 5 || len(record) == len(historyColumns)):
+			o, err = parseOperation(record, 1e9)
+			if err == nil {
+				id = o.key
+			}
+			if err == nil && len(record) > 5 {
+				err = parseRecord(record[5:], &o.OpRecord)
+			}
+		default:
+			err = errors.New("operation history file format error")
+		}
+		if err != nil {
+			return err
+		}
+		h.AddOperation(id, o)
+	}
+}
+
+// parseOperation parses key, input, output, start and end of operation, with timestamps in given unit of nanoseconds
+func parseOperation(record []string, unit float64) (*operation, error) {
+	var err error
+	o := new(operation)
+	o.key, err = strconv.Atoi(record[0])
+	if err != nil {
+		return nil, err
+	}
+	if !isNull(record[1]) {
+		o.input = record[1]
+		o.Type = WriteOp
+	}
+	if !isNull(record[2]) {
+		o.output = record[2]
+	}
+	if unit == 1 {
+		if o.start, err = strconv.ParseInt(record[3], 10, 64); err != nil {
+			return nil, err
+		}
+		if o.end, err = strconv.ParseInt(record[4], 10, 64); err != nil {
+			return nil, err
+		}
+		return o, nil
+	}
+	start, err := strconv.ParseFloat(record[3], 64)
+	if err != nil {
+		return nil, err
+	}
+	end, err := strconv.ParseFloat(record[4], 64)
+	if err != nil {
+		return nil, err
+	}
+	o.start = int64(math.Round(start * unit))
+	o.end = int64(math.Round(end * unit))
+	// operation never returned
+	if end*unit >= math.MaxInt64 {
+		o.end = math.MaxInt64
+	}
+	return o, nil
+}
+
+// parseRecord parses client, command, type, replica and error of operation
+func parseRecord(record []string, r *OpRecord) error {
+	var err error
+	r.Client = ID(record[0])
+	if r.Command, err = strconv.Atoi(record[1]); err != nil {
+		return err
+	}
+	if r.Type, err = parseType(record[2]); err != nil {
+		return err
+	}
+	r.Replica = ID(record[3])
+	r.Err = record[4]
+	return nil
+}
+
+func parseType(s string) (OpType, error) {
+	switch s {
+	case "read":
+		return ReadOp, nil
+	case "write":
+		return WriteOp, nil
+	}
+	return ReadOp, fmt.Errorf("unknown operation type %s", s)
+}
+
+func isNull(s string) bool {
+	return s == "" || s == "null" || s == "<nil>"
+}
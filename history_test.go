@@ -0,0 +1,120 @@
+package paxi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testHistory returns history of operations with every kind of record
+func testHistory() *History {
+	h := NewHistory()
+	h.Add(1, 1, nil, 1000, 2000, OpRecord{Client: "1.1-0", Command: 1, Replica: "1.2"})
+	h.Add(1, nil, 1, 3000, 4000, OpRecord{Client: "1.1-0", Command: 2, Replica: "1.3"})
+	h.Add(2, nil, 0, 3500, 4500, OpRecord{Client: "1.1-1", Command: 1})
+	h.Add(2, 7, nil, 5000, math.MaxInt64, OpRecord{Client: "1.1-1", Command: 2, Err: `500 "timeout, retry"`})
+	return h
+}
+
+// equal compares operations of two histories, values are compared as strings
+func equal(t *testing.T, a, b *History) {
+	if len(a.operations) != len(b.operations) {
+		t.Fatalf("got %d operations, expected %d", len(b.operations), len(a.operations))
+	}
+	str := func(v interface{}) interface{} {
+		if v == nil {
+			return nil
+		}
+		return fmt.Sprint(v)
+	}
+	for i, x := range a.operations {
+		y := b.operations[i]
+		if x.key != y.key || str(x.input) != str(y.input) || str(x.output) != str(y.output) ||
+			x.start != y.start || x.end != y.end || !reflect.DeepEqual(x.OpRecord, y.OpRecord) {
+			t.Errorf("operation %d is %v %+v, expected %v %+v", i, y, y.OpRecord, x, x.OpRecord)
+		}
+	}
+	if !reflect.DeepEqual(keys(a), keys(b)) {
+		t.Errorf("got keys %v, expected %v", keys(b), keys(a))
+	}
+}
+
+func keys(h *History) map[int]int {
+	k := make(map[int]int)
+	for key, ops := range h.shard {
+		k[key] = len(ops)
+	}
+	return k
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	for _, format := range []string{HistoryCSV, HistoryJSONL} {
+		h := testHistory()
+		var buf bytes.Buffer
+		if err := h.Encode(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		r := NewHistory()
+		if err := r.Decode(&buf); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		equal(t, h, r)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := testHistory()
+	for path, file := range map[string]string{"a": "a.csv", "b.csv": "b.csv", "c.jsonl": "c.jsonl"} {
+		if err := h.WriteFile(filepath.Join(dir, path)); err != nil {
+			t.Fatal(err)
+		}
+		r := NewHistory()
+		if err := r.ReadFile(filepath.Join(dir, file)); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		equal(t, h, r)
+	}
+}
+
+func TestHistoryOldFormats(t *testing.T) {
+	expected := NewHistory()
+	expected.Add(1, 5, nil, 10000, 20000)
+	expected.Add(1, nil, 5, 30000, 40000)
+
+	for _, file := range []string{
+		// key, input, output, start, end in seconds
+		"1,5,<nil>,0.000010,0.000020\n1,<nil>,5,0.000030,0.000040\n",
+		// with client records
+		"1,5,<nil>,0.000010,0.000020,,0,write,,\n1,<nil>,5,0.000030,0.000040,,0,read,,\n",
+		// id, key, input, output, start, end in nanoseconds
+		"1,1,5,null,10000,20000\n1,1,null,5,30000,40000\n",
+	} {
+		h := NewHistory()
+		if err := h.Decode(strings.NewReader(file)); err != nil {
+			t.Fatalf("%q: %v", file, err)
+		}
+		equal(t, expected, h)
+	}
+
+	for _, file := range []string{
+		"#paxi-history,99\n",
+		`{"format":"paxi-history","version":99}` + "\n",
+		"1,2,3\n",
+	} {
+		if err := NewHistory().Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("%q is read without error", file)
+		}
+	}
+}
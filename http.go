@@ -2,11 +2,13 @@ package paxi
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ailidani/paxi/log"
@@ -18,6 +20,7 @@ const (
 	HTTPCommandID = "Cid"
 	HTTPTimestamp = "Timestamp"
 	HTTPNodeID    = "Id"
+	HTTPReplica   = "Replica"
 )
 
 // serve serves the http REST API request from clients
@@ -28,6 +31,8 @@ func (n *node) http() {
 	mux.HandleFunc("/crash", n.handleCrash)
 	mux.HandleFunc("/drop", n.handleDrop)
 	mux.HandleFunc("/RFL", n.handleRFL)
+	mux.HandleFunc("/stream", n.handleStream)
+	mux.HandleFunc("/watch", n.handleWatch)
 	// http string should be in form of ":8080"
 	//log.Debugf("Replica %s received clients readslot %s\n", n.id, config.HTTPAddrs)
 	url, err := url.Parse(config.HTTPAddrs[n.id])
@@ -83,6 +88,9 @@ func (n *node) handleRoot(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			cmd.Value = Value(body)
+		} else if slot := r.URL.Query().Get("slot"); slot != "" {
+			n.handleSnapshot(w, cmd.Key, slot)
+			return
 		}
 	} else {
 		body, err := ioutil.ReadAll(r.Body)
@@ -111,6 +119,7 @@ func (n *node) handleRoot(w http.ResponseWriter, r *http.Request) {
 	// set all http headers
 	w.Header().Set(HTTPClientID, string(reply.Command.ClientID))
 	w.Header().Set(HTTPCommandID, strconv.Itoa(reply.Command.CommandID))
+	w.Header().Set(HTTPReplica, string(n.id))
 	for k, v := range reply.Properties {
 		w.Header().Set(k, v)
 	}
@@ -122,6 +131,34 @@ func (n *node) handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleSnapshot reads key as of log slot from local database without going through the protocol,
+// with url = http://ip:port/key?slot=s
+func (n *node) handleSnapshot(w http.ResponseWriter, k Key, s string) {
+	slot, err := strconv.Atoi(s)
+	if err != nil {
+		http.Error(w, "invalid slot", http.StatusBadRequest)
+		return
+	}
+	v, err := n.Database.GetAt(k, slot)
+	switch err {
+	case nil:
+	case ErrNotExecuted:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case ErrCompacted:
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(HTTPNodeID, string(n.id))
+	_, err = w.Write(v)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
 func (n *node) handleRFL(w http.ResponseWriter, r *http.Request) {
 	var Antireq AntiEntropy
 	var cmd Command
@@ -189,6 +226,7 @@ func (n *node) handleRFL(w http.ResponseWriter, r *http.Request) {
 	// set all http headers
 	w.Header().Set(HTTPClientID, string(reply.Command.ClientID))
 	w.Header().Set(HTTPCommandID, strconv.Itoa(reply.Command.CommandID))
+	w.Header().Set(HTTPReplica, string(n.id))
 	for k, v := range reply.Properties {
 		w.Header().Set(k, v)
 	}
@@ -200,6 +238,126 @@ func (n *node) handleRFL(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleStream takes over the http connection to pipeline commands of one client,
+// each line is a json encoded Command and replies are returned as StreamReply in completion order
+func (n *node) handleStream(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer conn.Close()
+	rw.WriteString("HTTP/1.1 200 OK\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		log.Error(err)
+		return
+	}
+
+	var lock sync.Mutex
+	encoder := json.NewEncoder(rw)
+	decoder := json.NewDecoder(rw)
+	for {
+		var cmd Command
+		if err := decoder.Decode(&cmd); err != nil {
+			if err != io.EOF {
+				log.Error(err)
+			}
+			return
+		}
+		req := Request{
+			Command:    cmd,
+			Properties: make(map[string]string),
+			Timestamp:  time.Now().UnixNano(),
+			NodeID:     n.id,
+			c:          make(chan Reply, 1),
+		}
+		n.MessageChan <- req
+		go func(req Request) {
+			reply := <-req.c
+			m := StreamReply{
+				ClientID:  req.Command.ClientID,
+				CommandID: req.Command.CommandID,
+				Value:     reply.Value,
+			}
+			if reply.Err != nil {
+				m.Err = reply.Err.Error()
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			err := encoder.Encode(m)
+			if err == nil {
+				err = rw.Flush()
+			}
+			if err != nil {
+				log.Error(err)
+			}
+		}(req)
+	}
+}
+
+// handleWatch streams write commands executed on key or key range [from, to] as server-sent events,
+// with url = http://ip:port/watch?key=k or http://ip:port/watch?from=k1&to=k2
+func (n *node) handleWatch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var from, to int
+	var err error
+	if key := r.URL.Query().Get("key"); key != "" {
+		from, err = strconv.Atoi(key)
+		to = from
+	} else {
+		from, err = strconv.Atoi(r.URL.Query().Get("from"))
+		if err == nil {
+			to, err = strconv.Atoi(r.URL.Query().Get("to"))
+		}
+	}
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "invalide key", http.StatusBadRequest)
+		return
+	}
+
+	watcher := n.Database.Watch(Key(from), Key(to))
+	defer watcher.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set(HTTPNodeID, string(n.id))
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	closed := r.Context().Done()
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-watcher.C:
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", b)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
 func (n *node) handleHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(HTTPNodeID, string(n.id))
 	k, err := strconv.Atoi(r.URL.Query().Get("key"))
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"strconv"
 	"testing"
@@ -21,17 +22,21 @@ func RunServer(t *testing.T, port string) *http.Server {
 		w.Header().Set("id", id)
 		_, err := io.WriteString(w, i)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	})
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
 	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
 	go func() {
-		err := server.ListenAndServe()
+		err := server.Serve(listener)
 		if err != http.ErrServerClosed {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	}()
 	return server
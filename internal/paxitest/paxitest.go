@@ -0,0 +1,294 @@
+// Package paxitest runs clusters of protocol replicas within one test process, it is only used by tests.
+// Nodes are connected by the chan transport and call registered handle functions like paxi nodes,
+// with random message delays and failures injected by tests.
+package paxitest
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// Delay is the default maximum random delay of messages sent by new nodes
+const Delay = 500 * time.Microsecond
+
+// Timeout is how long tests wait for a request or condition before failing
+const Timeout = 5 * time.Second
+
+var _ paxi.Node = (*Node)(nil)
+
+var (
+	once    sync.Once
+	mu      sync.Mutex
+	running []*Node // nodes of current configuration
+)
+
+// Configure installs paxi configuration of nodes ids on chan transport, logs are written into a temporary directory.
+// Nodes of previous configuration are failed, so that replicas of a finished test do not interfere with later ones
+func Configure(tb testing.TB, ids ...paxi.ID) {
+	once.Do(func() {
+		dir, err := ioutil.TempDir("", "paxitest")
+		if err != nil {
+			tb.Fatal(err)
+		}
+		log.SetupDir(dir)
+	})
+
+	mu.Lock()
+	for _, n := range running {
+		n.Fail(true)
+	}
+	running = nil
+	mu.Unlock()
+
+	config := paxi.MakeDefaultConfig()
+	config.Addrs = make(map[paxi.ID]string)
+	config.HTTPAddrs = make(map[paxi.ID]string)
+	for _, id := range ids {
+		config.Addrs[id] = "chan://" + string(id)
+	}
+	paxi.SetConfig(config)
+}
+
+// Node stubs paxi.Node with socket over chan transport and in-memory database,
+// every message and local function is handled by one goroutine once the node runs
+type Node struct {
+	paxi.Socket
+	paxi.Database
+	id paxi.ID
+
+	// Delay is the maximum random delay of every sent message, messages are reordered if positive
+	Delay time.Duration
+
+	// Lost returns true if message m sent to node to is lost
+	Lost func(to paxi.ID, m interface{}) bool
+
+	handles map[string]reflect.Value
+	local   chan interface{}
+
+	mu     sync.RWMutex
+	failed bool
+}
+
+// NewNode creates node id listening on chan transport with message Delay, Configure must be called first
+func NewNode(id paxi.ID) *Node {
+	n := &Node{
+		Socket:   paxi.NewSocket(id, paxi.GetConfig().Addrs),
+		Database: paxi.NewDatabase(),
+		id:       id,
+		Delay:    Delay,
+		handles:  make(map[string]reflect.Value),
+		local:    make(chan interface{}, paxi.GetConfig().ChanBufferSize),
+	}
+	mu.Lock()
+	running = append(running, n)
+	mu.Unlock()
+	return n
+}
+
+// ID returns id of node
+func (n *Node) ID() paxi.ID {
+	return n.id
+}
+
+// Register a handle function for each message type
+func (n *Node) Register(m interface{}, f interface{}) {
+	t := reflect.TypeOf(m)
+	fn := reflect.ValueOf(f)
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 1 || fn.Type().In(0) != t {
+		panic("register handle function error")
+	}
+	n.handles[t.String()] = fn
+}
+
+// Run starts handling messages and returns
+func (n *Node) Run() {
+	go func() {
+		for {
+			m := n.Recv()
+			if !n.Failed() {
+				n.local <- m
+			}
+		}
+	}()
+	go func() {
+		for m := range n.local {
+			if f, ok := m.(func()); ok {
+				f()
+				continue
+			}
+			v := reflect.ValueOf(m)
+			f, exists := n.handles[v.Type().String()]
+			if !exists {
+				log.Fatalf("no registered handle function for message type %v", v.Type())
+			}
+			f.Call([]reflect.Value{v})
+		}
+	}()
+}
+
+// Local delivers message m to the handle function of this node, m can also be a function to call
+func (n *Node) Local(m interface{}) {
+	n.local <- m
+}
+
+// Do calls f by the handle goroutine of node and waits for it to return
+func (n *Node) Do(f func()) {
+	done := make(chan struct{})
+	n.Local(func() {
+		f()
+		close(done)
+	})
+	<-done
+}
+
+// Request issues command c from client to node like a request received by http server, and returns its reply channel
+func (n *Node) Request(c paxi.Command) <-chan paxi.Reply {
+	r, reply := paxi.NewRequest(c)
+	r.NodeID = n.id
+	n.Local(r)
+	return reply
+}
+
+// Call issues command c to node and waits for its reply, the test fails if c is not completed within Timeout or fails
+func (n *Node) Call(tb testing.TB, c paxi.Command) paxi.Reply {
+	select {
+	case reply := <-n.Request(c):
+		if reply.Err != nil {
+			tb.Fatalf("request %v to %s failed: %v", c, n.id, reply.Err)
+		}
+		return reply
+	case <-time.After(Timeout):
+		tb.Fatalf("request %v to %s is not completed", c, n.id)
+	}
+	return paxi.Reply{}
+}
+
+// Retry handles request r again
+func (n *Node) Retry(r paxi.Request) {
+	n.Local(r)
+}
+
+// Forward sends request r to node id, its reply goes to the client of r directly as the request is not encoded
+func (n *Node) Forward(id paxi.ID, r paxi.Request) {
+	r.NodeID = n.id
+	n.Send(id, r)
+}
+
+// RelpyForward does nothing as forwarded requests are replied directly
+func (n *Node) RelpyForward(c paxi.Command, reply paxi.Reply) {}
+
+// Fail makes node lose every message it sends or receives until it recovers by Fail(false)
+func (n *Node) Fail(failed bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failed = failed
+}
+
+// Failed returns true if node is failed
+func (n *Node) Failed() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.failed
+}
+
+// Send sends message m to node to after random delay, unless node is failed or m is lost
+func (n *Node) Send(to paxi.ID, m interface{}) {
+	if n.Failed() || n.Lost != nil && n.Lost(to, m) {
+		return
+	}
+	if n.Delay <= 0 {
+		n.Socket.Send(to, m)
+		return
+	}
+	go func() {
+		time.Sleep(time.Duration(rand.Int63n(int64(n.Delay))))
+		n.Socket.Send(to, m)
+	}()
+}
+
+// Broadcast sends message m to every other node
+func (n *Node) Broadcast(m interface{}) {
+	for id := range paxi.GetConfig().Addrs {
+		if id != n.id {
+			n.Send(id, m)
+		}
+	}
+}
+
+// MulticastZone sends message m to every other node in zone
+func (n *Node) MulticastZone(zone int, m interface{}) {
+	for id := range paxi.GetConfig().Addrs {
+		if id != n.id && id.Zone() == zone {
+			n.Send(id, m)
+		}
+	}
+}
+
+// MulticastQuorum sends message m to quorum number of other nodes
+func (n *Node) MulticastQuorum(quorum int, m interface{}) {
+	i := 0
+	for id := range paxi.GetConfig().Addrs {
+		if i == quorum {
+			break
+		}
+		if id != n.id {
+			n.Send(id, m)
+			i++
+		}
+	}
+}
+
+// Eventually waits until check returns nil, the test fails with the last error of check if it does not within Timeout
+func Eventually(tb testing.TB, check func() error) {
+	deadline := time.Now().Add(Timeout)
+	for err := check(); err != nil; err = check() {
+		if time.Now().After(deadline) {
+			tb.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Linearizable runs workers concurrently, each issues ops random reads and writes of three keys to one of nodes in turn,
+// and fails the test if history of the operations is not linearizable
+func Linearizable(tb testing.TB, nodes []*Node, workers, ops int) {
+	h := paxi.NewHistory()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int, n *Node) {
+			defer wg.Done()
+			for i := 1; i <= ops; i++ {
+				k := rand.Intn(3)
+				c := paxi.Command{Key: paxi.Key(k), ClientID: paxi.ID(strconv.Itoa(w)), CommandID: i}
+				v := w*1000 + i
+				if rand.Intn(2) == 0 {
+					c.Value = paxi.Value(strconv.Itoa(v))
+				}
+				s := time.Since(start).Nanoseconds()
+				r := <-n.Request(c)
+				e := time.Since(start).Nanoseconds()
+				if c.IsWrite() {
+					h.Add(k, v, nil, s, e)
+				} else {
+					x, _ := strconv.Atoi(string(r.Value))
+					h.Add(k, nil, x, s, e)
+				}
+			}
+		}(w, nodes[w%len(nodes)])
+	}
+	wg.Wait()
+
+	if n := h.Linearizable(); n != 0 {
+		tb.Errorf("history has %d anomalies", n)
+	}
+}
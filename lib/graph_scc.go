@@ -39,7 +39,7 @@ func (data *data) strongConnect(v interface{}) *node {
 	data.nodes = append(data.nodes, node{lowlink: index, stacked: true})
 	node := &data.nodes[index]
 
-	for _, w := range data.graph[v] {
+	for w := range data.graph[v] {
 		i, seen := data.index[w]
 		if !seen {
 			n := data.strongConnect(w)
@@ -22,3 +22,30 @@ func TestGraoh(t *testing.T) {
 		t.Fatal("graph cannot detect cycle")
 	}
 }
+
+func TestGraphSCC(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge(1, 2)
+	g.AddEdge(2, 3)
+	g.AddEdge(3, 2)
+	g.AddEdge(3, 4)
+	g.AddEdge(5, 1)
+
+	scc := g.SCC()
+	if len(scc) != 4 {
+		t.Fatalf("graph SCC() = %v, want 4 components", scc)
+	}
+	// components are in reverse topological order
+	order := make(map[interface{}]int)
+	for i, c := range scc {
+		for _, v := range c {
+			order[v] = i
+		}
+	}
+	if order[2] != order[3] {
+		t.Errorf("graph SCC() = %v, 2 and 3 should be in one component", scc)
+	}
+	if !(order[4] < order[2] && order[2] < order[1] && order[1] < order[5]) {
+		t.Errorf("graph SCC() = %v, not in reverse topological order", scc)
+	}
+}
@@ -0,0 +1,265 @@
+package paxi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// An exact linearizability checker of key-value register histories,
+// based on the algorithm of Wing & Gong with memoization of Lowe, as implemented by Porcupine
+// https://www.cs.cmu.edu/~wing/publications/WingGong93.pdf
+// http://www.cs.ox.ac.uk/people/gavin.lowe/LinearizabiltyTesting/
+
+// Violation is a counterexample of a consistency model found in one key,
+// for linearizability and sequential consistency it is a minimal sub-history,
+// removing any of its operations makes it consistent
+type Violation struct {
+	Anomaly    string
+	Key        int
+	Operations []*operation
+}
+
+func (v Violation) String() string {
+	ops := make([]string, len(v.Operations))
+	for i, o := range v.Operations {
+		ops[i] = o.String()
+	}
+	return fmt.Sprintf("%s key=%d [%s]", v.Anomaly, v.Key, strings.Join(ops, ", "))
+}
+
+// LinearizableExact concurrently checks each partition of the history with exhaustive search,
+// and returns the counterexample of every key that is not linearizable
+func (h *History) LinearizableExact() []Violation {
+	violations := make(chan *Violation)
+	h.RLock()
+	defer h.RUnlock()
+	for k, partition := range h.shard {
+		go func(k int, p []*operation) {
+			if linearizable(p) {
+				violations <- nil
+				return
+			}
+			violations <- &Violation{Anomaly: "linearizability", Key: k, Operations: minimize(p, linearizable)}
+		}(k, checkable(partition))
+	}
+	result := make([]Violation, 0)
+	for range h.shard {
+		if v := <-violations; v != nil {
+			result = append(result, *v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// step applies operation o to register of value state, returns false if o is a read of other value
+func step(state interface{}, o *operation) (bool, interface{}) {
+	// write
+	if o.input != nil {
+		return true, o.input
+	}
+	// read of key never written returns zero value
+	if state == nil {
+		return zero(o.output), state
+	}
+	return fmt.Sprint(state) == fmt.Sprint(o.output), state
+}
+
+func zero(v interface{}) bool {
+	switch v {
+	case nil, 0, "", "0":
+		return true
+	}
+	return false
+}
+
+// event of call or return in history
+type event struct {
+	op    int // index of operation
+	call  bool
+	time  int64
+	match *event // return event of call
+	prev  *event
+	next  *event
+}
+
+// lift removes call event and its return event from list
+func (e *event) lift() {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	r := e.match
+	r.prev.next = r.next
+	if r.next != nil {
+		r.next.prev = r.prev
+	}
+}
+
+// unlift puts back call event and its return event into list
+func (e *event) unlift() {
+	r := e.match
+	r.prev.next = r
+	if r.next != nil {
+		r.next.prev = r
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << uint(i%64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << uint(i%64) }
+
+func (b bitset) clone() bitset {
+	c := make(bitset, len(b))
+	copy(c, b)
+	return c
+}
+
+func (b bitset) key() string {
+	return fmt.Sprint([]uint64(b))
+}
+
+// linearizable searches for a linearization of operations on one key
+func linearizable(ops []*operation) bool {
+	return linearizableFrom(ops, nil)
+}
+
+// linearizableFrom searches for a linearization of operations on one key with initial value init,
+// nil is the initial value of key never written
+func linearizableFrom(ops []*operation, init interface{}) bool {
+	if len(ops) == 0 {
+		return true
+	}
+
+	// build list of events in time order, calls before returns of the same time as operations are concurrent
+	events := make([]*event, 0, 2*len(ops))
+	for i, o := range ops {
+		c := &event{op: i, call: true, time: o.start}
+		r := &event{op: i, time: o.end}
+		if o.indeterminate() {
+			r.time = math.MaxInt64
+		}
+		c.match = r
+		events = append(events, c, r)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return events[i].call && !events[j].call
+	})
+	head := new(event)
+	prev := head
+	for _, e := range events {
+		prev.next = e
+		e.prev = prev
+		prev = e
+	}
+
+	type frame struct {
+		call  *event
+		state interface{}
+	}
+	stack := make([]frame, 0, len(ops))
+	linearized := make(bitset, len(ops)/64+1)
+	cache := make(map[string]bool)
+	state := init
+
+	e := head.next
+	for head.next != nil {
+		if e.call {
+			ok, next := step(state, ops[e.op])
+			if ok {
+				l := linearized.clone()
+				l.set(e.op)
+				key := l.key() + fmt.Sprintf("|%v", next)
+				if !cache[key] {
+					cache[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					linearized.set(e.op)
+					e.lift()
+					e = head.next
+					continue
+				}
+			}
+			e = e.next
+			continue
+		}
+
+		// only indeterminate writes are left, which may never take effect
+		if e.time == math.MaxInt64 {
+			return true
+		}
+
+		// return event of operation that cannot be linearized, backtrack
+		if len(stack) == 0 {
+			return false
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = f.state
+		linearized.clear(f.call.op)
+		f.call.unlift()
+		e = f.call.next
+	}
+	return true
+}
+
+// written returns the set of values written by ops
+func written(ops []*operation) map[string]bool {
+	w := make(map[string]bool)
+	for _, o := range ops {
+		if o.input != nil {
+			w[fmt.Sprint(o.input)] = true
+		}
+	}
+	return w
+}
+
+// complete returns true if every read in ops of a value written in all operations reads a write in ops
+func complete(ops []*operation, all map[string]bool) bool {
+	w := written(ops)
+	for _, o := range ops {
+		if o.input == nil && all[fmt.Sprint(o.output)] && !w[fmt.Sprint(o.output)] {
+			return false
+		}
+	}
+	return true
+}
+
+// minimize removes operations from inconsistent history as long as it stays inconsistent,
+// by chunks of halving size until no single operation can be removed.
+// Writes of values that are read are kept, so a violation is just a read only if it reads a value never written.
+func minimize(ops []*operation, consistent func([]*operation) bool) []*operation {
+	ops = append([]*operation(nil), ops...)
+	all := written(ops)
+	// reduce tries to remove every chunk of n operations and returns true if any is removed
+	reduce := func(n int) bool {
+		removed := false
+		for i := 0; i < len(ops); {
+			end := i + n
+			if end > len(ops) {
+				end = len(ops)
+			}
+			rest := append(append([]*operation(nil), ops[:i]...), ops[end:]...)
+			if len(rest) > 0 && complete(rest, all) && !consistent(rest) {
+				ops = rest
+				removed = true
+			} else {
+				i = end
+			}
+		}
+		return removed
+	}
+	for n := len(ops) / 2; n > 1; n /= 2 {
+		reduce(n)
+	}
+	for reduce(1) {
+	}
+	sort.Sort(byTime(ops))
+	return ops
+}
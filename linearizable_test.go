@@ -0,0 +1,94 @@
+package paxi
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLinearizableExact(t *testing.T) {
+	h := NewHistory()
+	// key 1 is linearizable with concurrent write and reads
+	h.Add(1, 1, nil, 0, 100)
+	h.Add(1, nil, 0, 10, 20)
+	h.Add(1, nil, 1, 30, 40)
+	h.Add(1, nil, 1, 110, 120)
+
+	// key 2 reads a value overwritten before the read starts
+	h.Add(2, 1, nil, 0, 10)
+	h.Add(2, 2, nil, 20, 30)
+	h.Add(2, nil, 1, 40, 50)
+	h.Add(2, nil, 2, 60, 70)
+	h.Add(2, 3, nil, 80, 90)
+
+	// key 3 reads old value after a read of new value
+	h.Add(3, 1, nil, 0, 100)
+	h.Add(3, nil, 1, 10, 20)
+	h.Add(3, nil, 0, 30, 40)
+
+	v := h.LinearizableExact()
+	if len(v) != 2 {
+		t.Fatalf("expected violations of key 2 and 3, got %v", v)
+	}
+	if v[0].Key != 2 || len(v[0].Operations) != 3 {
+		t.Errorf("expected minimal violation of 3 operations on key 2, got %v", v[0])
+	}
+	if v[1].Key != 3 || len(v[1].Operations) != 3 {
+		t.Errorf("expected minimal violation of 3 operations on key 3, got %v", v[1])
+	}
+	for _, o := range v[0].Operations {
+		if o.input == 3 || o.output == 2 {
+			t.Errorf("violation of key 2 includes unrelated operation %v", o)
+		}
+	}
+}
+
+func TestLinearizableExactConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	register := make(map[int]int)
+	h := NewHistory()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= 200; i++ {
+				k := rand.Intn(4)
+				s := time.Since(start).Nanoseconds()
+				mu.Lock()
+				if rand.Intn(2) == 0 {
+					v := w*1000 + i
+					register[k] = v
+					mu.Unlock()
+					h.Add(k, v, nil, s, time.Since(start).Nanoseconds())
+				} else {
+					v := register[k]
+					mu.Unlock()
+					h.Add(k, nil, v, s, time.Since(start).Nanoseconds())
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if v := h.LinearizableExact(); len(v) != 0 {
+		t.Errorf("linearizable history has violations %v", v)
+	}
+}
+
+func TestLinearizableExactUnknownValue(t *testing.T) {
+	// key is written before the history starts
+	h := NewHistory()
+	h.Add(1, nil, 9, 0, 10)
+	h.Add(1, 1, nil, 20, 30)
+	h.Add(1, nil, 1, 40, 50)
+	h.Add(1, 2, nil, 60, 70)
+	h.Add(1, nil, 2, 80, 90)
+
+	v := h.LinearizableExact()
+	if len(v) != 1 || len(v[0].Operations) != 1 || v[0].Operations[0].output != 9 {
+		t.Errorf("expected violation of read of unknown value, got %v", v)
+	}
+}
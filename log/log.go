@@ -110,11 +110,16 @@ func init() {
 	log.err = stdlog.New(os.Stderr, "[ERROR] ", format)
 }
 
-// Setup setup log format and output file
+// Setup setup log format and output file in log_dir
 func Setup() {
+	SetupDir(log.dir)
+}
+
+// SetupDir setup log format and output file in directory dir
+func SetupDir(dir string) {
 	format := stdlog.Ldate | stdlog.Ltime | stdlog.Lmicroseconds | stdlog.Lshortfile
 	fname := fmt.Sprintf("%s.%d.log", filepath.Base(os.Args[0]), os.Getpid())
-	f, err := os.Create(filepath.Join(log.dir, fname))
+	f, err := os.Create(filepath.Join(dir, fname))
 	if err != nil {
 		stdlog.Fatal(err)
 	}
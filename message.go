@@ -3,6 +3,7 @@ package paxi
 import (
 	"encoding/gob"
 	"fmt"
+	"time"
 
 	"github.com/ailidani/paxi/log"
 )
@@ -16,6 +17,7 @@ func init() {
 	gob.Register(TransactionReply{})
 	gob.Register(Register{})
 	gob.Register(Config{})
+	gob.Register(ReplyError(""))
 }
 
 /***************************
@@ -53,6 +55,18 @@ type AntiEntropy struct {
 }
 
 
+// NewRequest creates request of command c issued within the process, e.g. by tests,
+// its reply is received from the returned channel
+func NewRequest(c Command) (Request, <-chan Reply) {
+	r := Request{
+		Command:    c,
+		Properties: make(map[string]string),
+		Timestamp:  time.Now().UnixNano(),
+		c:          make(chan Reply, 1),
+	}
+	return r, r.c
+}
+
 // Reply replies to current client session
 func (r *Request) Reply(reply Reply) {
 	log.Debugf("RECEVIE the reply %v", reply)
@@ -76,6 +90,25 @@ func (r Reply) String() string {
 	return fmt.Sprintf("Reply {cmd=%v value=%x prop=%v}", r.Command, r.Value, r.Properties)
 }
 
+// ReplyError is the error of Reply in a form that can be encoded over the network
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return string(e)
+}
+
+// StreamReply is the reply of one command pipelined over a client stream
+type StreamReply struct {
+	ClientID  ID
+	CommandID int
+	Value     Value
+	Err       string
+}
+
+func (r StreamReply) String() string {
+	return fmt.Sprintf("StreamReply {id=%s cid=%d value=%x err=%s}", r.ClientID, r.CommandID, r.Value, r.Err)
+}
+
 // Read can be used as a special request that directly read the value of key without go through replication protocol in Replica
 type Read struct {
 	CommandID int
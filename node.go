@@ -3,6 +3,7 @@ package paxi
 import (
 	"net/http"
 	"reflect"
+	"strconv"
 	"sync"
 
 	"github.com/ailidani/paxi/log"
@@ -16,6 +17,7 @@ type Node interface {
 	ID() ID
 	Run()
 	Retry(r Request)
+	Local(m interface{})
 	Forward(id ID, r Request)
 	Register(m interface{}, f interface{})
 	RelpyForward(c Command, resp Reply)
@@ -37,10 +39,14 @@ type node struct {
 
 // NewNode creates a new Node object from configuration
 func NewNode(id ID) Node {
+	db, err := OpenDatabase(id)
+	if err != nil {
+		log.Fatal("database open error: ", err)
+	}
 	return &node{
 		id:          id,
 		Socket:      NewSocket(id, config.Addrs),
-		Database:    NewDatabase(),
+		Database:    db,
 		MessageChan: make(chan interface{}, config.ChanBufferSize),
 		handles:     make(map[string]reflect.Value),
 		forwards:    make(map[string]*Request),
@@ -56,6 +62,12 @@ func (n *node) Retry(r Request) {
 	n.MessageChan <- r
 }
 
+// Local delivers message m to the handle function of this node without going through network,
+// it is used by timers of protocols so that protocol state is only accessed by the handle goroutine
+func (n *node) Local(m interface{}) {
+	n.MessageChan <- m
+}
+
 // Register a handle function for each message type
 func (n *node) Register(m interface{}, f interface{}) {
 	t := reflect.TypeOf(m)
@@ -73,6 +85,9 @@ func (n *node) Run() {
 		go n.handle()
 		go n.recv()
 	}
+	if addr, ok := config.TCPAddrs[n.id]; ok {
+		n.serveTCP(addr)
+	}
 	n.http()
 }
 
@@ -91,11 +106,10 @@ func (n *node) recv() {
 			continue
 
 		case Reply:
-			n.RLock()
-			r := n.forwards[m.Command.String()]
 			log.Debugf("node %v received reply %v", n.id, m)
-			n.RUnlock()
-			r.Reply(m)
+			if r := n.takeForward(m.Command); r != nil {
+				r.Reply(m)
+			}
 			continue
 		}
 		n.MessageChan <- m
@@ -168,17 +182,35 @@ func (n *node) Forward(id ID, m Request) {
 	log.Debugf("Node %v forwarding %v to %s", n.ID(), m, id)
 	m.NodeID = n.id
 	n.Lock()
-	n.forwards[m.Command.String()] = &m
+	n.forwards[forwardKey(m.Command)] = &m
 	n.Unlock()
 	n.Send(id, m)
 }
+
 func (n *node) RelpyForward(c Command, resp Reply) {
-	n.RLock()
-	r, ok := n.forwards[c.String()]
-	if !ok {
+	r := n.takeForward(c)
+	if r == nil {
 		return
 	}
-	n.RUnlock()
 	r.Reply(resp)
 	log.Debugf("node %v received reply %v", n.id, c)
 }
+
+// takeForward removes and returns the pending forwarded request of command c
+func (n *node) takeForward(c Command) *Request {
+	key := forwardKey(c)
+	n.Lock()
+	defer n.Unlock()
+	r := n.forwards[key]
+	delete(n.forwards, key)
+	return r
+}
+
+// forwardKey identifies a forwarded request by its client session and command id,
+// commands without a session fall back to the full command
+func forwardKey(c Command) string {
+	if c.ClientID == "" || c.CommandID <= 0 {
+		return c.String()
+	}
+	return string(c.ClientID) + "/" + strconv.Itoa(c.CommandID)
+}
@@ -0,0 +1,238 @@
+package paxi
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/ailidani/paxi/log"
+)
+
+// anomalyMetric counts anomalies found by online checkers, exported as expvar "anomalies"
+var anomalyMetric = expvar.NewInt("anomalies")
+
+// OnlineWindow is the default number of completed operations of one key checked at once
+const OnlineWindow = 1000
+
+// OnlineChecker checks linearizability of operations of every key as they complete, without keeping the whole history.
+// Whenever no operation of a key is in flight, or its window of completed operations is full,
+// the completed operations that precede every operation in flight are checked
+// from every possible value of the key, then pruned and only the possible values after them are kept.
+// Indeterminate writes are kept as they may take effect later, which can only hide anomalies but never report false ones.
+// Each key is checked under its own lock, so checking one key never blocks operations of other keys.
+type OnlineChecker struct {
+	sync.Mutex
+	keys      map[int]*window
+	anomalies int
+
+	// Window is the number of completed operations of one key that forces a check while operations are in flight,
+	// the window is dropped if it cannot be pruned at all, and operations in flight then are never reported as anomalies
+	Window int
+
+	// Alert is called with counterexample of every anomaly found, after it is logged
+	Alert func(Violation)
+}
+
+// window of operations of one key since last check
+type window struct {
+	sync.Mutex
+	pending map[*operation]int64 // operations invoked but not completed, with time before their invocation
+	ops     []*operation         // completed operations
+	states  []interface{}        // possible values of key before ops, nil is the initial value
+	dropped map[*operation]bool  // operations in flight when window was dropped, they may precede dropped operations
+}
+
+// NewOnlineChecker creates new online checker
+func NewOnlineChecker() *OnlineChecker {
+	return &OnlineChecker{
+		keys:   make(map[int]*window),
+		Window: OnlineWindow,
+	}
+}
+
+// Anomalies returns number of anomalies found so far
+func (c *OnlineChecker) Anomalies() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.anomalies
+}
+
+func (c *OnlineChecker) window(key int) *window {
+	c.Lock()
+	defer c.Unlock()
+	w, exists := c.keys[key]
+	if !exists {
+		w = &window{
+			pending: make(map[*operation]int64),
+			ops:     make([]*operation, 0),
+			states:  []interface{}{nil},
+			dropped: make(map[*operation]bool),
+		}
+		c.keys[key] = w
+	}
+	return w
+}
+
+// Begin must be called with operation o of key before it is invoked, t is any time before its start
+func (c *OnlineChecker) Begin(o *operation, t int64) {
+	w := c.window(o.key)
+	w.Lock()
+	defer w.Unlock()
+	w.pending[o] = t
+}
+
+// Add adds completed operation previously begun,
+// and checks its key when no operation is in flight or the window is full
+func (c *OnlineChecker) Add(o *operation) {
+	w := c.window(o.key)
+	w.Lock()
+	defer w.Unlock()
+	delete(w.pending, o)
+	if o.indeterminate() {
+		// may take effect after all dropped operations
+		delete(w.dropped, o)
+	}
+	if o.input != nil || !o.indeterminate() {
+		w.ops = append(w.ops, o)
+	}
+	if len(w.pending) == 0 {
+		c.check(o.key, w)
+	} else if c.Window > 0 && len(w.ops) >= c.Window {
+		c.check(o.key, w)
+		if len(w.ops) >= c.Window {
+			log.Warningf("Online checker drops %d operations of key %d blocked by operations in flight", len(w.ops), o.key)
+			c.drop(w)
+		}
+	}
+}
+
+// cut returns the time that splits completed operations of window into those ending before it,
+// which precede every operation in flight and every other completed operation, and the rest
+func (w *window) cut() int64 {
+	t := int64(math.MaxInt64)
+	for _, b := range w.pending {
+		if b < t {
+			t = b
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, o := range w.ops {
+			if !o.indeterminate() && o.end >= t && o.start < t {
+				t = o.start
+				changed = true
+			}
+		}
+	}
+	return t
+}
+
+// candidates returns the possible values of key after operations of window
+func (w *window) candidates() map[string]interface{} {
+	candidates := make(map[string]interface{})
+	for _, s := range w.states {
+		candidates[fmt.Sprint(s)] = s
+	}
+	for _, o := range w.ops {
+		if o.input != nil {
+			candidates[fmt.Sprint(o.input)] = o.input
+		}
+	}
+	return candidates
+}
+
+// check checks completed operations of window before the cut and prunes them
+func (c *OnlineChecker) check(key int, w *window) {
+	t := w.cut()
+	checked := make([]*operation, 0)
+	kept := make([]*operation, 0)
+	end := int64(math.MinInt64)
+	dropped := false
+	for _, o := range w.ops {
+		if w.dropped[o] && o.end < t {
+			dropped = true
+			delete(w.dropped, o)
+		}
+		switch {
+		case o.indeterminate():
+			// may take effect at any later time
+			checked = append(checked, o)
+			kept = append(kept, o)
+		case o.end < t:
+			checked = append(checked, o)
+			if o.end > end {
+				end = o.end
+			}
+		default:
+			kept = append(kept, o)
+		}
+	}
+	if end == math.MinInt64 {
+		// nothing to prune
+		return
+	}
+
+	consistent := func(ops []*operation) bool {
+		for _, s := range w.states {
+			if linearizableFrom(ops, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	candidates := w.candidates()
+	states := make([]interface{}, 0, len(candidates))
+	if dropped {
+		// operations in flight when window was dropped may take effect before any dropped operation,
+		// so they cannot be checked and the key may have any value after them
+		for _, s := range candidates {
+			states = append(states, s)
+		}
+	} else if consistent(checked) {
+		// possible values after checked operations are values read right after them
+		for _, s := range candidates {
+			read := &operation{key: key, output: s, start: end + 1, end: end + 1}
+			if consistent(append(checked[:len(checked):len(checked)], read)) {
+				states = append(states, s)
+			}
+		}
+	} else {
+		v := Violation{Anomaly: "linearizability", Key: key, Operations: minimize(checked, consistent)}
+		c.Lock()
+		c.anomalies++
+		c.Unlock()
+		anomalyMetric.Add(1)
+		log.Errorf("Online checker found anomaly %v", v)
+		if c.Alert != nil {
+			c.Alert(v)
+		}
+		// continue from any value
+		for _, s := range candidates {
+			states = append(states, s)
+		}
+	}
+
+	w.states = states
+	w.ops = kept
+}
+
+// drop gives up checking completed operations of window, and continues from any value of the key
+func (c *OnlineChecker) drop(w *window) {
+	candidates := w.candidates()
+	w.states = make([]interface{}, 0, len(candidates))
+	for _, s := range candidates {
+		w.states = append(w.states, s)
+	}
+	ops := make([]*operation, 0)
+	for _, o := range w.ops {
+		if o.indeterminate() {
+			ops = append(ops, o)
+		}
+	}
+	w.ops = ops
+	for o := range w.pending {
+		w.dropped[o] = true
+	}
+}
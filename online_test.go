@@ -0,0 +1,190 @@
+package paxi
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestOnlineChecker(t *testing.T) {
+	c := NewOnlineChecker()
+	alerts := make([]Violation, 0)
+	c.Alert = func(v Violation) { alerts = append(alerts, v) }
+	metric := anomalyMetric.Value()
+
+	add := func(o *operation) {
+		c.Begin(o, o.start)
+		c.Add(o)
+	}
+
+	// concurrent write and read are checked together once both complete
+	w := &operation{key: 1, input: 1, start: 0, end: 100}
+	r := &operation{key: 1, output: 1, start: 10, end: 20}
+	c.Begin(w, 0)
+	c.Begin(r, 10)
+	c.Add(w)
+	if len(c.keys[1].ops) != 1 {
+		t.Fatalf("operations checked while another is in flight")
+	}
+	c.Add(r)
+	if len(c.keys[1].ops) != 0 {
+		t.Fatalf("checked operations not pruned")
+	}
+
+	add(&operation{key: 1, input: 2, start: 200, end: 300})
+	add(&operation{key: 1, output: 2, start: 400, end: 500})
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected anomalies %v", alerts)
+	}
+
+	// stale read after pruned writes
+	add(&operation{key: 1, output: 1, start: 600, end: 700})
+	if len(alerts) != 1 || alerts[0].Key != 1 {
+		t.Fatalf("expected anomaly of key 1, got %v", alerts)
+	}
+	if c.Anomalies() != 1 || anomalyMetric.Value() != metric+1 {
+		t.Errorf("anomaly count is %d and metric is %d", c.Anomalies(), anomalyMetric.Value()-metric)
+	}
+
+	// timed out write takes effect after later reads, failed read is ignored
+	add(&operation{key: 2, input: 1, start: 0, end: 10})
+	add(&operation{key: 2, input: 2, start: 20, end: math.MaxInt64, OpRecord: OpRecord{Err: "timeout"}})
+	add(&operation{key: 2, output: 1, start: 30, end: 40})
+	add(&operation{key: 2, output: 0, start: 50, end: math.MaxInt64, OpRecord: OpRecord{Err: "timeout"}})
+	add(&operation{key: 2, output: 2, start: 60, end: 70})
+	if len(alerts) != 1 {
+		t.Errorf("unexpected anomalies %v", alerts[1:])
+	}
+	if len(c.keys[2].ops) != 1 {
+		t.Errorf("indeterminate write not kept")
+	}
+}
+
+func TestOnlineCheckerConcurrent(t *testing.T) {
+	for _, window := range []int{OnlineWindow, 8} {
+		testOnlineCheckerConcurrent(t, window)
+	}
+}
+
+// testOnlineCheckerConcurrent simulates clients of a linearizable register with a fixed seed,
+// every operation takes effect at a random step between its invocation and response
+func testOnlineCheckerConcurrent(t *testing.T, window int) {
+	c := NewOnlineChecker()
+	c.Window = window
+	rnd := rand.New(rand.NewSource(1))
+	register := make(map[int]int)
+	type client struct {
+		op      *operation
+		applied bool
+	}
+	clients := make([]client, 8)
+	done := 0
+	for step := int64(0); done < 8*500; step++ {
+		i := rnd.Intn(len(clients))
+		cl := &clients[i]
+		switch {
+		case cl.op == nil:
+			o := &operation{key: rnd.Intn(4), start: step}
+			if rnd.Intn(2) == 0 {
+				o.input = i*10000 + done
+			}
+			c.Begin(o, step)
+			cl.op = o
+		case !cl.applied:
+			if cl.op.input != nil {
+				register[cl.op.key] = cl.op.input.(int)
+			} else {
+				cl.op.output = register[cl.op.key]
+			}
+			cl.applied = true
+		case i > 0 || rnd.Intn(20) == 0:
+			// first client is slow to complete its operations so that windows are dropped
+			cl.op.end = step
+			c.Add(cl.op)
+			cl.op, cl.applied = nil, false
+			done++
+		}
+	}
+	for _, cl := range clients {
+		if cl.op != nil {
+			if !cl.applied && cl.op.input == nil {
+				cl.op.output = register[cl.op.key]
+			}
+			cl.op.end = math.MaxInt32
+			c.Add(cl.op)
+		}
+	}
+
+	if n := c.Anomalies(); n != 0 {
+		t.Errorf("linearizable history has %d anomalies with window %d", n, window)
+	}
+	for k, w := range c.keys {
+		if len(w.ops) != 0 || len(w.pending) != 0 {
+			t.Errorf("key %d has %d operations and %d pending after all completed", k, len(w.ops), len(w.pending))
+		}
+	}
+}
+
+func TestOnlineCheckerWindow(t *testing.T) {
+	c := NewOnlineChecker()
+	c.Window = 4
+	alerts := make([]Violation, 0)
+	c.Alert = func(v Violation) { alerts = append(alerts, v) }
+
+	// long read is in flight while window fills up
+	slow := &operation{key: 1, output: 1}
+	c.Begin(slow, 25)
+	for i, o := range []*operation{
+		{key: 1, input: 1, start: 0, end: 10},
+		{key: 1, output: 1, start: 12, end: 20},
+		{key: 1, input: 2, start: 30, end: 40},
+		{key: 1, output: 2, start: 50, end: 60},
+	} {
+		c.Begin(o, o.start)
+		c.Add(o)
+		if i < 3 && len(c.keys[1].ops) != i+1 {
+			t.Fatalf("window checked before it is full")
+		}
+	}
+	// operations before the slow read are pruned
+	if len(c.keys[1].ops) != 2 {
+		t.Fatalf("full window has %d operations after check, expected 2", len(c.keys[1].ops))
+	}
+
+	// slow read of value overwritten after it began is linearizable
+	slow.start, slow.end = 26, 70
+	c.Add(slow)
+	if len(alerts) != 0 || len(c.keys[1].ops) != 0 {
+		t.Fatalf("unexpected anomalies %v", alerts)
+	}
+
+	// window that cannot be pruned is dropped
+	stuck := &operation{key: 2}
+	c.Begin(stuck, 0)
+	for i := 0; i < 4; i++ {
+		o := &operation{key: 2, input: i + 1, start: int64(10 * (i + 1)), end: int64(10*(i+1) + 5)}
+		c.Begin(o, o.start)
+		c.Add(o)
+	}
+	if len(c.keys[2].ops) != 0 {
+		t.Errorf("window blocked by operation in flight is not dropped")
+	}
+
+	// operation in flight took effect before dropped writes, a later read of a dropped write is no anomaly
+	stuck.input, stuck.start, stuck.end = 9, 1, 60
+	c.Add(stuck)
+	read := &operation{key: 2, output: 4, start: 70, end: 80}
+	c.Begin(read, read.start)
+	c.Add(read)
+	if len(alerts) != 0 {
+		t.Errorf("false anomalies %v after window is dropped", alerts)
+	}
+
+	// checking continues once operations in flight at drop are checked
+	stale := &operation{key: 2, output: 1, start: 90, end: 100}
+	c.Begin(stale, stale.start)
+	c.Add(stale)
+	if len(alerts) != 1 {
+		t.Errorf("stale read after dropped window is not reported")
+	}
+}
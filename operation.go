@@ -1,6 +1,35 @@
 package paxi
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
+
+// OpType is type of operation in History
+type OpType int
+
+// operation types
+const (
+	ReadOp OpType = iota
+	WriteOp
+)
+
+func (t OpType) String() string {
+	if t == WriteOp {
+		return "write"
+	}
+	return "read"
+}
+
+// OpRecord identifies the client command of an operation and its outcome.
+// Every client session issues its commands sequentially.
+type OpRecord struct {
+	Client  ID     // client session issued the operation
+	Command int    // command id in client session
+	Type    OpType // read or write
+	Replica ID     // replica served the operation
+	Err     string // error returned to client, empty if succeeded
+}
 
 type operation struct {
 	key    int
@@ -9,6 +38,23 @@ type operation struct {
 	// timestamps
 	start int64
 	end   int64
+	OpRecord
+}
+
+// indeterminate returns true if operation failed or never returned, a write of which may or may not take effect
+func (a operation) indeterminate() bool {
+	return a.Err != "" || a.end == math.MaxInt64
+}
+
+// checkable returns operations without indeterminate reads, as their outputs are unknown
+func checkable(ops []*operation) []*operation {
+	c := make([]*operation, 0, len(ops))
+	for _, o := range ops {
+		if o.input != nil || !o.indeterminate() {
+			c = append(c, o)
+		}
+	}
+	return c
 }
 
 func (a operation) happenBefore(b operation) bool {
@@ -24,7 +70,10 @@ func (a operation) equal(b operation) bool {
 }
 
 func (a operation) String() string {
-	return fmt.Sprintf("{input=%v, output=%v, start=%d, end=%d}", a.input, a.output, a.start, a.end)
+	if a.Client == "" {
+		return fmt.Sprintf("{input=%v, output=%v, start=%d, end=%d}", a.input, a.output, a.start, a.end)
+	}
+	return fmt.Sprintf("{client=%s, cid=%d, input=%v, output=%v, start=%d, end=%d}", a.Client, a.Command, a.input, a.output, a.start, a.end)
 }
 
 // sort operations by invocation time
@@ -0,0 +1,157 @@
+package paxos
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// ErrBarrier is returned by quorum read when the read barrier is not reached within barrierReads attempts
+var ErrBarrier = errors.New("read barrier not reached")
+
+// barrierReads bounds the number of reads waiting for the read barrier, and barrierDelay is the delay between them
+const (
+	barrierReads = 100
+	barrierDelay = time.Millisecond
+)
+
+// Client sends commands directly to the leader learned from replies
+// Client is safe for concurrent use by multiple goroutines
+type Client struct {
+	*paxi.HTTPClient
+
+	mu     sync.RWMutex // protects ballot
+	ballot paxi.Ballot
+}
+
+// NewClient creates a new Paxos client
+func NewClient(id paxi.ID) *Client {
+	return &Client{
+		HTTPClient: paxi.NewHTTPClient(id),
+	}
+}
+
+// Get implements paxi.Client interface
+// there are three reading modes:
+// (1) read as normal command through leader
+// (2) read from leader with current ballot number
+// (3) read from quorum of replicas with barrier
+func (c *Client) Get(key paxi.Key) (paxi.Value, error) {
+	switch *read {
+	case "quorum":
+		return c.readQuorum(key)
+	case "any":
+		v, _, err := c.RESTGet(c.ID, key)
+		return v, err
+	default:
+		// "leader" and normal reads
+		v, meta, err := c.RESTGet(c.leader(), key)
+		c.updateBallot(meta)
+		return v, err
+	}
+}
+
+// Put implements paxi.Client interface
+func (c *Client) Put(key paxi.Key, value paxi.Value) error {
+	_, meta, err := c.RESTPut(c.leader(), key, value)
+	c.updateBallot(meta)
+	return err
+}
+
+// leader returns the leader of highest known ballot, or the node client connects to if unknown
+func (c *Client) leader() paxi.ID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.ballot == 0 {
+		return c.ID
+	}
+	return c.ballot.ID()
+}
+
+// updateBallot keeps the highest ballot seen from replies
+func (c *Client) updateBallot(meta map[string]string) {
+	s, exists := meta[HTTPHeaderBallot]
+	if !exists || s == "" {
+		return
+	}
+	b := paxi.NewBallotFromString(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b > c.ballot {
+		c.ballot = b
+	}
+}
+
+// readQuorum reads from a majority and waits until the highest accepted slot among them is executed
+func (c *Client) readQuorum(key paxi.Key) (paxi.Value, error) {
+	majority := c.N/2 + 1
+	barrier := -1
+	numReachedBarrier := 0
+	numInProgress := 0
+	var value paxi.Value
+
+	// quorum read
+	values, metadatas := c.QuorumGet(key)
+	for i, v := range values {
+		slot, err := strconv.Atoi(metadatas[i][HTTPHeaderSlot])
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		inProgress, err := strconv.ParseBool(metadatas[i][HTTPHeaderInProgress])
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if inProgress {
+			numInProgress++
+		}
+		if slot > barrier {
+			barrier = slot
+			numReachedBarrier = 1
+			value = v
+		} else if slot == barrier {
+			numReachedBarrier++
+		}
+	}
+
+	// wait for slot to be executed by any node
+	for i := 0; numInProgress > 0 && numReachedBarrier < majority; i++ {
+		if i >= barrierReads {
+			return nil, ErrBarrier
+		}
+		if i > 0 {
+			time.Sleep(barrierDelay)
+		}
+		// read from random node
+		_, metadata, err := c.RESTGet("", key)
+		if err != nil {
+			return nil, err
+		}
+		// get executed slot
+		execute, err := strconv.Atoi(metadata[HTTPHeaderExecute])
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if execute >= barrier {
+			break
+		}
+
+		// get highest accepted slot
+		slot, err := strconv.Atoi(metadata[HTTPHeaderSlot])
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if slot >= barrier {
+			numReachedBarrier++
+		}
+	}
+
+	return value, nil
+}
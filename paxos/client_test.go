@@ -0,0 +1,78 @@
+package paxos
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+// quorumClient returns client of servers replying with value and headers of reply(id, number of reads so far)
+func quorumClient(t *testing.T, reply func(id paxi.ID, reads int) (string, map[string]string)) (*Client, func()) {
+	paxitest.Configure(t, ids...)
+	c := NewClient("1.1")
+	c.HTTP = make(map[paxi.ID]string)
+	c.N = len(ids)
+	var reads int32
+	servers := make([]*httptest.Server, 0)
+	for _, id := range ids {
+		id := id
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, meta := reply(id, int(atomic.AddInt32(&reads, 1)))
+			for k, m := range meta {
+				w.Header().Set(k, m)
+			}
+			io.WriteString(w, v)
+		}))
+		servers = append(servers, s)
+		c.HTTP[id] = s.URL
+	}
+	return c, func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}
+}
+
+func TestReadQuorum(t *testing.T) {
+	// write of slot 5 is accepted by 1.2 and executed by every replica after 10 reads
+	c, done := quorumClient(t, func(id paxi.ID, reads int) (string, map[string]string) {
+		execute := 4
+		if reads > 10 {
+			execute = 5
+		}
+		if id == "1.2" {
+			return "new", map[string]string{HTTPHeaderSlot: "5", HTTPHeaderInProgress: "true", HTTPHeaderExecute: strconv.Itoa(execute)}
+		}
+		return "old", map[string]string{HTTPHeaderSlot: "4", HTTPHeaderInProgress: "false", HTTPHeaderExecute: strconv.Itoa(execute)}
+	})
+	defer done()
+
+	v, err := c.readQuorum(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "new" {
+		t.Errorf("quorum read = %q, expected value of in progress write", v)
+	}
+}
+
+func TestReadQuorumBarrier(t *testing.T) {
+	// replicas never report executed slot, the read barrier is never reached
+	c, done := quorumClient(t, func(id paxi.ID, reads int) (string, map[string]string) {
+		if id == "1.2" {
+			return "new", map[string]string{HTTPHeaderSlot: "5", HTTPHeaderInProgress: "true"}
+		}
+		return "old", map[string]string{HTTPHeaderSlot: "4", HTTPHeaderInProgress: "false"}
+	})
+	defer done()
+
+	if _, err := c.readQuorum(1); err != ErrBarrier {
+		t.Errorf("quorum read returns error %v, expected %v", err, ErrBarrier)
+	}
+}
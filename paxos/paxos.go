@@ -0,0 +1,365 @@
+package paxos
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// entry in log
+type entry struct {
+	ballot    paxi.Ballot
+	command   paxi.Command
+	commit    bool
+	request   *paxi.Request
+	quorum    *paxi.Quorum
+	timestamp time.Time
+}
+
+// Paxos instance of classic Multi-Paxos with a stable leader,
+// committed commands are executed strictly in slot order
+type Paxos struct {
+	paxi.Node
+
+	log     map[int]*entry // log ordered by slot
+	execute int            // next execute slot number
+	active  bool           // active leader
+	ballot  paxi.Ballot    // highest ballot number
+	slot    int            // highest slot number
+
+	quorum   *paxi.Quorum    // phase 1 quorum
+	requests []*paxi.Request // phase 1 pending requests
+
+	Q1              func(*paxi.Quorum) bool
+	Q2              func(*paxi.Quorum) bool
+	ReplyWhenCommit bool
+}
+
+// NewPaxos creates new paxos instance
+func NewPaxos(n paxi.Node, options ...func(*Paxos)) *Paxos {
+	p := &Paxos{
+		Node:            n,
+		log:             make(map[int]*entry, paxi.GetConfig().BufferSize),
+		slot:            -1,
+		quorum:          paxi.NewQuorum(),
+		requests:        make([]*paxi.Request, 0),
+		Q1:              func(q *paxi.Quorum) bool { return q.Majority() },
+		Q2:              func(q *paxi.Quorum) bool { return q.Majority() },
+		ReplyWhenCommit: false,
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+// IsLeader indecates if this node is current leader
+func (p *Paxos) IsLeader() bool {
+	return p.active || p.ballot.ID() == p.ID()
+}
+
+// Leader returns leader id of the current ballot
+func (p *Paxos) Leader() paxi.ID {
+	return p.ballot.ID()
+}
+
+// Ballot returns current ballot
+func (p *Paxos) Ballot() paxi.Ballot {
+	return p.ballot
+}
+
+// SetActive sets current paxos instance as active leader
+func (p *Paxos) SetActive(active bool) {
+	p.active = active
+}
+
+// SetBallot sets a new ballot number
+func (p *Paxos) SetBallot(b paxi.Ballot) {
+	p.ballot = b
+}
+
+// HandleRequest handles request and start phase 1 or phase 2
+func (p *Paxos) HandleRequest(r paxi.Request) {
+	if !p.active {
+		p.requests = append(p.requests, &r)
+		// current phase 1 pending
+		if p.ballot.ID() != p.ID() {
+			p.P1a()
+		}
+	} else {
+		p.P2a(&r)
+	}
+}
+
+// P1a starts phase 1 prepare
+func (p *Paxos) P1a() {
+	if p.active {
+		return
+	}
+	p.ballot.Next(p.ID())
+	p.quorum.Reset()
+	p.quorum.ACK(p.ID())
+	p.Broadcast(P1a{Ballot: p.ballot})
+}
+
+// P2a starts phase 2 accept
+func (p *Paxos) P2a(r *paxi.Request) {
+	p.slot++
+	p.log[p.slot] = &entry{
+		ballot:    p.ballot,
+		command:   r.Command,
+		request:   r,
+		quorum:    paxi.NewQuorum(),
+		timestamp: time.Now(),
+	}
+	p.log[p.slot].quorum.ACK(p.ID())
+	m := P2a{
+		Ballot:  p.ballot,
+		Slot:    p.slot,
+		Command: r.Command,
+	}
+	if paxi.GetConfig().Thrifty {
+		p.MulticastQuorum(paxi.GetConfig().N()/2+1, m)
+	} else {
+		p.Broadcast(m)
+	}
+}
+
+// HandleP1a handles P1a message
+func (p *Paxos) HandleP1a(m P1a) {
+	// new leader
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		// forward pending requests to new leader
+		p.forward()
+	}
+
+	l := make(map[int]CommandBallot)
+	for s := p.execute; s <= p.slot; s++ {
+		if p.log[s] == nil || p.log[s].commit {
+			continue
+		}
+		l[s] = CommandBallot{p.log[s].command, p.log[s].ballot}
+	}
+
+	p.Send(m.Ballot.ID(), P1b{
+		Ballot: p.ballot,
+		ID:     p.ID(),
+		Log:    l,
+	})
+}
+
+// update keeps the command of highest ballot for each uncommitted slot
+func (p *Paxos) update(scb map[int]CommandBallot) {
+	for s, cb := range scb {
+		p.slot = paxi.Max(p.slot, s)
+		if e, exists := p.log[s]; exists {
+			if !e.commit && cb.Ballot > e.ballot {
+				// propose the request again in a new slot
+				if !e.command.Equal(cb.Command) && e.request != nil {
+					p.requests = append(p.requests, e.request)
+					e.request = nil
+				}
+				e.ballot = cb.Ballot
+				e.command = cb.Command
+			}
+		} else {
+			p.log[s] = &entry{
+				ballot:  cb.Ballot,
+				command: cb.Command,
+			}
+		}
+	}
+}
+
+// HandleP1b handles P1b message
+func (p *Paxos) HandleP1b(m P1b) {
+	// old message
+	if m.Ballot < p.ballot || p.active {
+		return
+	}
+
+	// reject message
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		p.forward()
+		return
+	}
+
+	// ack message
+	if m.Ballot.ID() == p.ID() && m.Ballot == p.ballot {
+		p.update(m.Log)
+		p.quorum.ACK(m.ID)
+		if p.Q1(p.quorum) {
+			p.active = true
+			// propose any uncommitted entries, holes are filled with empty command
+			for s := p.execute; s <= p.slot; s++ {
+				e, exists := p.log[s]
+				if !exists {
+					e = &entry{}
+					p.log[s] = e
+				}
+				if e.commit {
+					continue
+				}
+				e.ballot = p.ballot
+				e.quorum = paxi.NewQuorum()
+				e.quorum.ACK(p.ID())
+				e.timestamp = time.Now()
+				p.Broadcast(P2a{
+					Ballot:  p.ballot,
+					Slot:    s,
+					Command: e.command,
+				})
+			}
+			// propose new commands
+			for _, req := range p.requests {
+				p.P2a(req)
+			}
+			p.requests = make([]*paxi.Request, 0)
+		}
+	}
+}
+
+// HandleP2a handles P2a message
+func (p *Paxos) HandleP2a(m P2a) {
+	if m.Ballot >= p.ballot {
+		if m.Ballot > p.ballot {
+			p.ballot = m.Ballot
+			p.active = false
+			p.forward()
+		}
+		// update slot number
+		p.slot = paxi.Max(p.slot, m.Slot)
+		// update entry
+		if e, exists := p.log[m.Slot]; exists {
+			if !e.commit && m.Ballot > e.ballot {
+				// different command and request is not nil
+				if !e.command.Equal(m.Command) && e.request != nil {
+					p.Forward(m.Ballot.ID(), *e.request)
+					e.request = nil
+				}
+				e.command = m.Command
+				e.ballot = m.Ballot
+			}
+		} else if m.Slot >= p.execute {
+			p.log[m.Slot] = &entry{
+				ballot:  m.Ballot,
+				command: m.Command,
+			}
+		}
+	}
+
+	p.Send(m.Ballot.ID(), P2b{
+		Ballot: p.ballot,
+		ID:     p.ID(),
+		Slot:   m.Slot,
+	})
+}
+
+// HandleP2b handles P2b message
+func (p *Paxos) HandleP2b(m P2b) {
+	// old message
+	e, exists := p.log[m.Slot]
+	if !exists || m.Ballot < e.ballot || e.commit {
+		return
+	}
+
+	// reject message
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		p.forward()
+		return
+	}
+
+	// ack message
+	if m.Ballot.ID() == p.ID() && m.Ballot == e.ballot && e.quorum != nil {
+		e.quorum.ACK(m.ID)
+		if p.Q2(e.quorum) {
+			e.commit = true
+			p.Broadcast(P3{
+				Ballot:  m.Ballot,
+				Slot:    m.Slot,
+				Command: e.command,
+			})
+
+			if p.ReplyWhenCommit && e.request != nil {
+				e.request.Reply(paxi.Reply{
+					Command:   e.command,
+					Timestamp: e.timestamp.Unix(),
+				})
+				e.request = nil
+			}
+			p.exec()
+		}
+	}
+}
+
+// HandleP3 handles phase 3 commit message
+func (p *Paxos) HandleP3(m P3) {
+	p.slot = paxi.Max(p.slot, m.Slot)
+	// already executed
+	if m.Slot < p.execute {
+		return
+	}
+
+	e, exists := p.log[m.Slot]
+	if exists {
+		if !e.command.Equal(m.Command) && e.request != nil {
+			p.Forward(m.Ballot.ID(), *e.request)
+			e.request = nil
+		}
+	} else {
+		e = new(entry)
+		p.log[m.Slot] = e
+	}
+
+	e.ballot = m.Ballot
+	e.command = m.Command
+	e.commit = true
+
+	p.exec()
+}
+
+// exec executes committed commands in slot order and stops at the first uncommitted slot
+func (p *Paxos) exec() {
+	for {
+		e, ok := p.log[p.execute]
+		if !ok || !e.commit {
+			break
+		}
+
+		log.Debugf("Replica %s execute [s=%d, cmd=%v]", p.ID(), p.execute, e.command)
+		value := p.ExecuteAt(p.execute, e.command)
+		if e.request != nil {
+			reply := paxi.Reply{
+				Command:    e.command,
+				Value:      value,
+				Properties: make(map[string]string),
+				Timestamp:  time.Now().Unix(),
+			}
+			reply.Properties[HTTPHeaderSlot] = strconv.Itoa(p.execute)
+			reply.Properties[HTTPHeaderBallot] = e.ballot.String()
+			reply.Properties[HTTPHeaderExecute] = strconv.Itoa(p.execute)
+			e.request.Reply(reply)
+			e.request = nil
+		}
+		// TODO clean up the log periodically
+		delete(p.log, p.execute)
+		p.execute++
+	}
+}
+
+func (p *Paxos) forward() {
+	for _, m := range p.requests {
+		p.Forward(p.ballot.ID(), *m)
+	}
+	p.requests = make([]*paxi.Request, 0)
+}
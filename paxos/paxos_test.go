@@ -0,0 +1,103 @@
+package paxos
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+var ids = []paxi.ID{"1.1", "1.2", "1.3"}
+
+func cluster(t *testing.T) (map[paxi.ID]*paxitest.Node, map[paxi.ID]*Replica) {
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	replicas := make(map[paxi.ID]*Replica)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+	return nodes, replicas
+}
+
+// executed waits until every replica executed n slots
+func executed(t *testing.T, nodes map[paxi.ID]*paxitest.Node, replicas map[paxi.ID]*Replica, n int) {
+	for _, id := range ids {
+		paxitest.Eventually(t, func() error {
+			var execute int
+			nodes[id].Do(func() { execute = replicas[id].execute })
+			if execute < n {
+				return fmt.Errorf("replica %s executed %d of %d slots", id, execute, n)
+			}
+			return nil
+		})
+	}
+}
+
+func TestCommit(t *testing.T) {
+	nodes, replicas := cluster(t)
+
+	// writes to any replica are committed by the leader in slot order
+	for i := 0; i < 10; i++ {
+		c := paxi.Command{Key: paxi.Key(i + 1), Value: paxi.Value(strconv.Itoa(i + 1))}
+		reply := nodes[ids[i%len(ids)]].Call(t, c)
+		if reply.Properties[HTTPHeaderSlot] != strconv.Itoa(i) {
+			t.Errorf("write %v committed in slot %s, expected %d", c, reply.Properties[HTTPHeaderSlot], i)
+		}
+	}
+	for _, id := range ids {
+		var leader paxi.ID
+		nodes[id].Do(func() { leader = replicas[id].Leader() })
+		if leader != "1.1" {
+			t.Errorf("replica %s has leader %s", id, leader)
+		}
+	}
+
+	// reads go through the leader and see every committed write
+	for i := 1; i <= 10; i++ {
+		reply := nodes[ids[i%len(ids)]].Call(t, paxi.Command{Key: paxi.Key(i)})
+		if string(reply.Value) != strconv.Itoa(i) {
+			t.Errorf("read of key %d = %q", i, reply.Value)
+		}
+	}
+
+	// every replica executes all committed commands
+	executed(t, nodes, replicas, 20)
+	for _, id := range ids {
+		if v := nodes[id].Get(10); string(v) != "10" {
+			t.Errorf("replica %s has value %q of key 10", id, v)
+		}
+	}
+}
+
+func TestReadInProgress(t *testing.T) {
+	nodes, replicas := cluster(t)
+	defer func(r string) { *read = r }(*read)
+
+	nodes["1.1"].Call(t, paxi.Command{Key: 1, Value: paxi.Value("1")})
+	executed(t, nodes, replicas, 1)
+
+	// write accepted but not committed at 1.2
+	*read = "quorum"
+	var b paxi.Ballot
+	nodes["1.1"].Do(func() { b = replicas["1.1"].Ballot() })
+	nodes["1.2"].Do(func() {
+		replicas["1.2"].HandleP2a(P2a{Ballot: b, Slot: 1, Command: paxi.Command{Key: 1, Value: paxi.Value("2")}})
+	})
+
+	reply := nodes["1.2"].Call(t, paxi.Command{Key: 1})
+	if string(reply.Value) != "2" || reply.Properties[HTTPHeaderInProgress] != "true" {
+		t.Errorf("read of in progress write = %q, in progress %s", reply.Value, reply.Properties[HTTPHeaderInProgress])
+	}
+	if reply.Properties[HTTPHeaderSlot] != "1" || reply.Properties[HTTPHeaderExecute] != "0" {
+		t.Errorf("read reply has slot %s and execute %s, expected 1 and 0", reply.Properties[HTTPHeaderSlot], reply.Properties[HTTPHeaderExecute])
+	}
+
+	reply = nodes["1.3"].Call(t, paxi.Command{Key: 1})
+	if string(reply.Value) != "1" || reply.Properties[HTTPHeaderInProgress] != "false" {
+		t.Errorf("read of executed write = %q, in progress %s", reply.Value, reply.Properties[HTTPHeaderInProgress])
+	}
+}
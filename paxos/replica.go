@@ -0,0 +1,86 @@
+package paxos
+
+import (
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+var ephemeralLeader = flag.Bool("ephemeral_leader", false, "unstable leader, if true paxos replica try to become leader instead of forward requests to current leader")
+var read = flag.String("read", "", "read from \"leader\", \"quorum\" or \"any\" replica")
+
+const (
+	HTTPHeaderSlot       = "Slot"
+	HTTPHeaderBallot     = "Ballot"
+	HTTPHeaderExecute    = "Execute"
+	HTTPHeaderInProgress = "Inprogress"
+)
+
+// Replica for one Paxos instance
+type Replica struct {
+	paxi.Node
+	*Paxos
+}
+
+// NewReplica generates new Paxos replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new Paxos replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.Paxos = NewPaxos(r)
+	// slots recovered from storage are not executed again
+	r.Paxos.execute = r.Slot() + 1
+	r.Paxos.slot = r.Slot()
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(P1a{}, r.HandleP1a)
+	r.Register(P1b{}, r.HandleP1b)
+	r.Register(P2a{}, r.HandleP2a)
+	r.Register(P2b{}, r.HandleP2b)
+	r.Register(P3{}, r.HandleP3)
+	return r
+}
+
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+
+	if m.Command.IsRead() && *read != "" {
+		v, inProgress := r.readInProgress(m)
+		reply := paxi.Reply{
+			Command:    m.Command,
+			Value:      v,
+			Properties: make(map[string]string),
+			Timestamp:  time.Now().Unix(),
+		}
+		reply.Properties[HTTPHeaderSlot] = strconv.Itoa(r.Paxos.slot)
+		reply.Properties[HTTPHeaderBallot] = r.Paxos.ballot.String()
+		reply.Properties[HTTPHeaderExecute] = strconv.Itoa(r.Paxos.execute - 1)
+		reply.Properties[HTTPHeaderInProgress] = strconv.FormatBool(inProgress)
+		m.Reply(reply)
+		return
+	}
+
+	if *ephemeralLeader || r.Paxos.IsLeader() || r.Paxos.Ballot() == 0 {
+		r.Paxos.HandleRequest(m)
+	} else {
+		go r.Forward(r.Paxos.Leader(), m)
+	}
+}
+
+// readInProgress returns the value of latest accepted write of key not executed yet if any,
+// otherwise the value in database
+func (r *Replica) readInProgress(m paxi.Request) (paxi.Value, bool) {
+	for i := r.Paxos.slot; i >= r.Paxos.execute; i-- {
+		e, exists := r.Paxos.log[i]
+		if exists && e.command.Key == m.Command.Key && e.command.IsWrite() {
+			return e.command.Value, true
+		}
+	}
+	return r.Node.Get(m.Command.Key), false
+}
@@ -1,8 +1,5 @@
 package paxi
 
-const X int = 2
-const K int = 1
-
 // Quorum records each acknowledgement and check for different types of quorum satisfied
 type Quorum struct {
 	Size  int
@@ -64,9 +61,10 @@ func (q *Quorum) Majority() bool {
 	return q.Size > config.n/2
 }
 
-// Majority+K quorum satisfied
+// MajorityX quorum of RS-Paxos satisfied,
+// any two such quorums intersect in at least ErasureData nodes to recover a value from its shards
 func (q *Quorum) MajorityX() bool {
-	return q.Size >= (config.n/2)+X
+	return q.Size >= (config.n+config.ErasureData+1)/2
 }
 
 // FastQuorum from fast paxos
@@ -0,0 +1,60 @@
+package raft
+
+import (
+	"sync"
+
+	"github.com/ailidani/paxi"
+)
+
+// Client sends commands directly to the leader learned from replies
+// Client is safe for concurrent use by multiple goroutines
+type Client struct {
+	*paxi.HTTPClient
+
+	mu     sync.RWMutex // protects leader
+	leader paxi.ID
+}
+
+// NewClient creates a new Raft client
+func NewClient(id paxi.ID) *Client {
+	return &Client{
+		HTTPClient: paxi.NewHTTPClient(id),
+	}
+}
+
+// Get implements paxi.Client interface
+func (c *Client) Get(key paxi.Key) (paxi.Value, error) {
+	v, meta, err := c.RESTGet(c.target(), key)
+	c.update(meta, err)
+	return v, err
+}
+
+// Put implements paxi.Client interface
+func (c *Client) Put(key paxi.Key, value paxi.Value) error {
+	_, meta, err := c.RESTPut(c.target(), key, value)
+	c.update(meta, err)
+	return err
+}
+
+// target returns the known leader, or the node client connects to if unknown
+func (c *Client) target() paxi.ID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.leader == "" {
+		return c.ID
+	}
+	return c.leader
+}
+
+// update keeps the leader of latest reply, and forgets it if request failed
+func (c *Client) update(meta map[string]string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.leader = ""
+		return
+	}
+	if l := meta[HTTPHeaderLeader]; l != "" {
+		c.leader = paxi.ID(l)
+	}
+}
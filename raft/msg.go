@@ -0,0 +1,75 @@
+package raft
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+)
+
+func init() {
+	gob.Register(RequestVote{})
+	gob.Register(RequestVoteReply{})
+	gob.Register(AppendEntries{})
+	gob.Register(AppendEntriesReply{})
+}
+
+// Entry of raft log
+type Entry struct {
+	Term    int
+	Command paxi.Command
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("Entry {t=%d cmd=%v}", e.Term, e.Command)
+}
+
+// RequestVote message sent by candidate
+type RequestVote struct {
+	Term         int
+	Candidate    paxi.ID
+	LastLogIndex int
+	LastLogTerm  int
+}
+
+func (m RequestVote) String() string {
+	return fmt.Sprintf("RequestVote {t=%d c=%s last=%d/%d}", m.Term, m.Candidate, m.LastLogIndex, m.LastLogTerm)
+}
+
+// RequestVoteReply message
+type RequestVoteReply struct {
+	Term    int
+	ID      paxi.ID // from node id
+	Granted bool
+}
+
+func (m RequestVoteReply) String() string {
+	return fmt.Sprintf("RequestVoteReply {t=%d id=%s granted=%t}", m.Term, m.ID, m.Granted)
+}
+
+// AppendEntries message sent by leader to replicate log and as heartbeat
+type AppendEntries struct {
+	Term         int
+	Leader       paxi.ID
+	PrevLogIndex int
+	PrevLogTerm  int
+	Entries      []Entry
+	LeaderCommit int
+}
+
+func (m AppendEntries) String() string {
+	return fmt.Sprintf("AppendEntries {t=%d l=%s prev=%d/%d n=%d commit=%d}", m.Term, m.Leader, m.PrevLogIndex, m.PrevLogTerm, len(m.Entries), m.LeaderCommit)
+}
+
+// AppendEntriesReply message
+type AppendEntriesReply struct {
+	Term    int
+	ID      paxi.ID // from node id
+	Success bool
+	Match   int // highest index matching leader log if success
+	Hint    int // next index leader should try if failed
+}
+
+func (m AppendEntriesReply) String() string {
+	return fmt.Sprintf("AppendEntriesReply {t=%d id=%s ok=%t match=%d hint=%d}", m.Term, m.ID, m.Success, m.Match, m.Hint)
+}
@@ -0,0 +1,390 @@
+package raft
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// maxEntries is the maximum number of entries in one AppendEntries message
+const maxEntries = 1000
+
+type role int
+
+// roles of raft server
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+// Raft instance with leader election, log replication and follower catch-up.
+// Committed entries are executed in log index order, reads go through the log as normal commands.
+// Term, vote and log are kept in memory only.
+type Raft struct {
+	paxi.Node
+
+	role     role
+	term     int     // current term
+	votedFor paxi.ID // candidate voted in current term
+	leader   paxi.ID // leader of current term if known
+
+	log         []Entry // log with a sentinel entry at index 0
+	commitIndex int
+	lastApplied int
+
+	votes    *paxi.Quorum
+	next     map[paxi.ID]int // leader: next index to send to each follower
+	match    map[paxi.ID]int // leader: highest replicated index of each follower
+	deadline time.Time       // election timeout
+
+	requests map[int]*paxi.Request // requests received as leader by log index
+	pending  []*paxi.Request       // requests received while leader is unknown
+
+	Timeout time.Duration // election timeout, randomized in [Timeout, 2*Timeout)
+}
+
+// NewRaft creates new raft instance
+func NewRaft(n paxi.Node, options ...func(*Raft)) *Raft {
+	r := &Raft{
+		Node:     n,
+		log:      make([]Entry, 1, paxi.GetConfig().BufferSize),
+		votes:    paxi.NewQuorum(),
+		next:     make(map[paxi.ID]int),
+		match:    make(map[paxi.ID]int),
+		requests: make(map[int]*paxi.Request, paxi.GetConfig().BufferSize),
+		pending:  make([]*paxi.Request, 0),
+		Timeout:  *timeout,
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	r.resetDeadline()
+	return r
+}
+
+// IsLeader indicates if this node is leader of current term
+func (r *Raft) IsLeader() bool {
+	return r.role == leader
+}
+
+// Leader returns leader of current term, empty if unknown
+func (r *Raft) Leader() paxi.ID {
+	return r.leader
+}
+
+// Term returns current term
+func (r *Raft) Term() int {
+	return r.term
+}
+
+func (r *Raft) lastIndex() int {
+	return len(r.log) - 1
+}
+
+func (r *Raft) lastTerm() int {
+	return r.log[len(r.log)-1].Term
+}
+
+func (r *Raft) resetDeadline() {
+	r.deadline = time.Now().Add(r.Timeout + time.Duration(rand.Int63n(int64(r.Timeout))))
+}
+
+// Tick is called periodically, leader sends heartbeats and followers start election after timeout
+func (r *Raft) Tick() {
+	if r.role == leader {
+		r.broadcast()
+		return
+	}
+	if time.Now().After(r.deadline) {
+		r.elect()
+	}
+}
+
+// HandleRequest appends request to log if leader, otherwise forwards it to leader
+func (r *Raft) HandleRequest(m paxi.Request) {
+	switch {
+	case r.role == leader:
+		r.propose(&m)
+	case r.leader != "":
+		go r.Forward(r.leader, m)
+	default:
+		r.pending = append(r.pending, &m)
+	}
+}
+
+func (r *Raft) propose(m *paxi.Request) {
+	r.log = append(r.log, Entry{Term: r.term, Command: m.Command})
+	r.requests[r.lastIndex()] = m
+	r.broadcast()
+	// single node
+	r.advance()
+}
+
+// forward sends pending requests to known leader
+func (r *Raft) forward() {
+	for _, m := range r.pending {
+		if r.role == leader {
+			r.propose(m)
+		} else {
+			go r.Forward(r.leader, *m)
+		}
+	}
+	r.pending = make([]*paxi.Request, 0)
+}
+
+// step updates to a higher term as follower
+func (r *Raft) step(term int) {
+	r.term = term
+	r.role = follower
+	r.votedFor = ""
+	r.leader = ""
+}
+
+// elect starts election for next term
+func (r *Raft) elect() {
+	r.step(r.term + 1)
+	r.role = candidate
+	r.votedFor = r.ID()
+	r.resetDeadline()
+	log.Debugf("Replica %s starts election of term %d", r.ID(), r.term)
+	r.votes.Reset()
+	r.votes.ACK(r.ID())
+	if r.votes.Majority() {
+		r.lead()
+		return
+	}
+	r.Broadcast(RequestVote{
+		Term:         r.term,
+		Candidate:    r.ID(),
+		LastLogIndex: r.lastIndex(),
+		LastLogTerm:  r.lastTerm(),
+	})
+}
+
+// lead becomes leader of current term and commits an empty entry to commit entries of previous terms
+func (r *Raft) lead() {
+	log.Infof("Replica %s becomes leader of term %d", r.ID(), r.term)
+	r.role = leader
+	r.leader = r.ID()
+	for _, id := range paxi.GetConfig().IDs() {
+		r.next[id] = r.lastIndex() + 1
+		r.match[id] = 0
+	}
+	r.log = append(r.log, Entry{Term: r.term})
+	r.broadcast()
+	r.advance()
+	r.forward()
+}
+
+// HandleRequestVote handles RequestVote message
+func (r *Raft) HandleRequestVote(m RequestVote) {
+	if m.Term > r.term {
+		r.step(m.Term)
+	}
+	// candidate log is at least as up-to-date as local log
+	uptodate := m.LastLogTerm > r.lastTerm() || m.LastLogTerm == r.lastTerm() && m.LastLogIndex >= r.lastIndex()
+	granted := m.Term == r.term && (r.votedFor == "" || r.votedFor == m.Candidate) && uptodate
+	if granted {
+		r.votedFor = m.Candidate
+		r.resetDeadline()
+	}
+	r.Send(m.Candidate, RequestVoteReply{
+		Term:    r.term,
+		ID:      r.ID(),
+		Granted: granted,
+	})
+}
+
+// HandleRequestVoteReply handles RequestVoteReply message
+func (r *Raft) HandleRequestVoteReply(m RequestVoteReply) {
+	if m.Term > r.term {
+		r.step(m.Term)
+		r.resetDeadline()
+		return
+	}
+	if r.role != candidate || m.Term != r.term || !m.Granted {
+		return
+	}
+	r.votes.ACK(m.ID)
+	if r.votes.Majority() {
+		r.lead()
+	}
+}
+
+// broadcast sends new entries to every follower, or heartbeat if follower is up to date
+func (r *Raft) broadcast() {
+	for _, id := range paxi.GetConfig().IDs() {
+		if id != r.ID() {
+			r.append(id)
+		}
+	}
+}
+
+// append sends entries from next index of follower id, and assumes they will be accepted
+func (r *Raft) append(id paxi.ID) {
+	prev := r.next[id] - 1
+	end := paxi.Min(r.lastIndex(), prev+maxEntries)
+	entries := make([]Entry, end-prev)
+	copy(entries, r.log[prev+1:end+1])
+	r.Send(id, AppendEntries{
+		Term:         r.term,
+		Leader:       r.ID(),
+		PrevLogIndex: prev,
+		PrevLogTerm:  r.log[prev].Term,
+		Entries:      entries,
+		LeaderCommit: r.commitIndex,
+	})
+	r.next[id] = end + 1
+}
+
+// HandleAppendEntries handles AppendEntries message
+func (r *Raft) HandleAppendEntries(m AppendEntries) {
+	reply := AppendEntriesReply{
+		Term: r.term,
+		ID:   r.ID(),
+	}
+	if m.Term < r.term {
+		r.Send(m.Leader, reply)
+		return
+	}
+	if m.Term > r.term {
+		r.step(m.Term)
+	}
+	// candidate steps down but keeps its vote of the same term
+	r.role = follower
+	r.resetDeadline()
+	if r.leader != m.Leader {
+		r.leader = m.Leader
+		r.forward()
+	}
+	reply.Term = r.term
+
+	if m.PrevLogIndex > r.lastIndex() {
+		reply.Hint = r.lastIndex() + 1
+		r.Send(m.Leader, reply)
+		return
+	}
+	if t := r.log[m.PrevLogIndex].Term; t != m.PrevLogTerm {
+		// skip the whole conflicting term
+		i := m.PrevLogIndex
+		for i > r.commitIndex+1 && r.log[i-1].Term == t {
+			i--
+		}
+		reply.Hint = i
+		r.Send(m.Leader, reply)
+		return
+	}
+
+	for j, e := range m.Entries {
+		i := m.PrevLogIndex + 1 + j
+		if i <= r.lastIndex() {
+			if r.log[i].Term == e.Term {
+				continue
+			}
+			r.truncate(i)
+		}
+		r.log = append(r.log, e)
+	}
+
+	reply.Success = true
+	reply.Match = m.PrevLogIndex + len(m.Entries)
+	r.Send(m.Leader, reply)
+
+	if commit := paxi.Min(m.LeaderCommit, reply.Match); commit > r.commitIndex {
+		r.commitIndex = commit
+		r.exec()
+	}
+}
+
+// truncate removes conflicting entries from index i, requests of removed entries are sent to leader again
+func (r *Raft) truncate(i int) {
+	for j := i; j <= r.lastIndex(); j++ {
+		if m, exists := r.requests[j]; exists {
+			r.pending = append(r.pending, m)
+			delete(r.requests, j)
+		}
+	}
+	r.log = r.log[:i]
+	r.forward()
+}
+
+// HandleAppendEntriesReply handles AppendEntriesReply message
+func (r *Raft) HandleAppendEntriesReply(m AppendEntriesReply) {
+	if m.Term > r.term {
+		r.step(m.Term)
+		r.resetDeadline()
+		return
+	}
+	if r.role != leader || m.Term != r.term {
+		return
+	}
+
+	if !m.Success {
+		// follower catches up from hint
+		r.next[m.ID] = paxi.Max(1, paxi.Min(r.next[m.ID]-1, m.Hint))
+		r.append(m.ID)
+		return
+	}
+
+	if m.Match > r.match[m.ID] {
+		r.match[m.ID] = m.Match
+	}
+	if r.next[m.ID] <= r.match[m.ID] {
+		r.next[m.ID] = r.match[m.ID] + 1
+	}
+	r.advance()
+}
+
+// advance commits the highest entry of current term replicated on majority
+func (r *Raft) advance() {
+	for i := r.lastIndex(); i > r.commitIndex && r.log[i].Term == r.term; i-- {
+		q := paxi.NewQuorum()
+		q.ACK(r.ID())
+		for id, match := range r.match {
+			if match >= i {
+				q.ACK(id)
+			}
+		}
+		if q.Majority() {
+			r.commitIndex = i
+			r.exec()
+			return
+		}
+	}
+}
+
+// exec executes committed entries in log order
+func (r *Raft) exec() {
+	for r.lastApplied < r.commitIndex {
+		r.lastApplied++
+		e := r.log[r.lastApplied]
+		log.Debugf("Replica %s execute [i=%d, cmd=%v]", r.ID(), r.lastApplied, e.Command)
+		// log index starts from 1 while database slots start from 0
+		value := r.ExecuteAt(r.lastApplied-1, e.Command)
+
+		m, exists := r.requests[r.lastApplied]
+		if !exists {
+			continue
+		}
+		delete(r.requests, r.lastApplied)
+		if !m.Command.Equal(e.Command) {
+			r.HandleRequest(*m)
+			continue
+		}
+		reply := paxi.Reply{
+			Command:    e.Command,
+			Value:      value,
+			Properties: make(map[string]string),
+			Timestamp:  time.Now().Unix(),
+		}
+		reply.Properties[HTTPHeaderLeader] = string(r.leader)
+		reply.Properties[HTTPHeaderTerm] = strconv.Itoa(r.term)
+		m.Reply(reply)
+	}
+}
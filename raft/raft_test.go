@@ -0,0 +1,112 @@
+package raft
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+// node records executed commands in order
+type node struct {
+	*paxitest.Node
+
+	sync.Mutex
+	executed []paxi.Command
+}
+
+func (n *node) ExecuteAt(slot int, c paxi.Command) paxi.Value {
+	n.Lock()
+	if !c.Empty() {
+		n.executed = append(n.executed, c)
+	}
+	n.Unlock()
+	return n.Node.ExecuteAt(slot, c)
+}
+
+func (n *node) commands() []paxi.Command {
+	n.Lock()
+	defer n.Unlock()
+	return append([]paxi.Command(nil), n.executed...)
+}
+
+func TestRaft(t *testing.T) {
+	ids := []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}
+	flag.Set("election_timeout", "50ms")
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*node)
+	replicas := make(map[paxi.ID]*Replica)
+	for _, id := range ids {
+		nodes[id] = &node{Node: paxitest.NewNode(id)}
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+
+	// leader returns the leader elected among live replicas
+	leader := func() (l paxi.ID) {
+		paxitest.Eventually(t, func() error {
+			for _, id := range ids {
+				var leader bool
+				if !nodes[id].Failed() {
+					nodes[id].Do(func() { leader = replicas[id].IsLeader() })
+				}
+				if leader {
+					l = id
+					return nil
+				}
+			}
+			return errors.New("no leader elected")
+		})
+		return
+	}
+	// write commits commands through leader l
+	write := func(l paxi.ID, from, to int) {
+		for i := from; i < to; i++ {
+			nodes[l].Call(t, paxi.Command{Key: paxi.Key(i), Value: paxi.Value(strconv.Itoa(i))})
+		}
+	}
+	// wait waits until every live replica executed n commands
+	wait := func(n int) {
+		for _, id := range ids {
+			paxitest.Eventually(t, func() error {
+				if c := nodes[id].commands(); !nodes[id].Failed() && len(c) < n {
+					return fmt.Errorf("replica %s executed %d of %d commands", id, len(c), n)
+				}
+				return nil
+			})
+		}
+	}
+
+	l := leader()
+	write(l, 0, 10)
+	wait(10)
+
+	// old leader crashes, new leader is elected and continues
+	nodes[l].Fail(true)
+	l2 := leader()
+	if l2 == l {
+		t.Fatalf("crashed leader %s is still leader", l)
+	}
+	write(l2, 10, 20)
+
+	// old leader recovers and catches up
+	nodes[l].Fail(false)
+	wait(20)
+	expected := nodes[ids[0]].commands()
+	for _, id := range ids[1:] {
+		if c := nodes[id].commands(); !reflect.DeepEqual(c, expected) {
+			t.Errorf("replica %s executed %v, expected %v", id, c, expected)
+		}
+	}
+	for _, id := range ids {
+		if v := nodes[id].Get(19); string(v) != "19" {
+			t.Errorf("replica %s has value %q of key 19", id, v)
+		}
+	}
+}
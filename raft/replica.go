@@ -0,0 +1,63 @@
+package raft
+
+import (
+	"flag"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+var timeout = flag.Duration("election_timeout", 500*time.Millisecond, "raft election timeout, leader sends heartbeats every 1/5 of timeout")
+
+const (
+	HTTPHeaderLeader = "Leader"
+	HTTPHeaderTerm   = "Term"
+)
+
+// tick is the timer message delivered to replica itself
+type tick struct{}
+
+// Replica for one Raft instance
+type Replica struct {
+	paxi.Node
+	*Raft
+}
+
+// NewReplica generates new Raft replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new Raft replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.Raft = NewRaft(r)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(tick{}, r.handleTick)
+	r.Register(RequestVote{}, r.HandleRequestVote)
+	r.Register(RequestVoteReply{}, r.HandleRequestVoteReply)
+	r.Register(AppendEntries{}, r.HandleAppendEntries)
+	r.Register(AppendEntriesReply{}, r.HandleAppendEntriesReply)
+	return r
+}
+
+// Run starts the timer and runs the node
+func (r *Replica) Run() {
+	go func() {
+		for range time.Tick(r.Raft.Timeout / 5) {
+			r.Local(tick{})
+		}
+	}()
+	r.Node.Run()
+}
+
+func (r *Replica) handleTick(tick) {
+	r.Raft.Tick()
+}
+
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+	r.Raft.HandleRequest(m)
+}
@@ -1,10 +1,17 @@
 package paxi
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 )
 
+// arrival schedules of open-loop load
+const (
+	ArrivalConstant = "constant"
+	ArrivalPoisson  = "poisson"
+)
+
 // Limiter limits operation rate when used with Wait function
 type Limiter struct {
 	sync.Mutex
@@ -48,3 +55,48 @@ func (l *Limiter) Wait() {
 		l.last = now
 	}
 }
+
+// Arrivals generates intended send times of open-loop load at fixed rate,
+// with constant inter-arrival times or exponential ones of a Poisson process.
+// Unlike Limiter, it never slows down the schedule when callers fall behind.
+type Arrivals struct {
+	sync.Mutex
+	next     time.Time
+	interval time.Duration
+	poisson  bool
+	rand     *rand.Rand
+}
+
+// NewArrivals creates new arrival schedule of rate operations per second, schedule is ArrivalConstant or ArrivalPoisson
+func NewArrivals(rate int, schedule string) *Arrivals {
+	return &Arrivals{
+		interval: time.Second / time.Duration(rate),
+		poisson:  schedule == ArrivalPoisson,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// gap returns time until next arrival
+func (a *Arrivals) gap() time.Duration {
+	if a.poisson {
+		return time.Duration(a.rand.ExpFloat64() * float64(a.interval))
+	}
+	return a.interval
+}
+
+// Wait blocks until next intended send time and returns it, or returns it immediately if it has passed
+func (a *Arrivals) Wait() time.Time {
+	a.Lock()
+	defer a.Unlock()
+
+	now := time.Now()
+	if a.next.IsZero() {
+		a.next = now
+	}
+	t := a.next
+	if t.After(now) {
+		time.Sleep(t.Sub(now))
+	}
+	a.next = t.Add(a.gap())
+	return t
+}
@@ -2,6 +2,7 @@ package paxi
 
 import (
 	"math"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -9,7 +10,8 @@ import (
 
 var total uint64
 
-func worker(tasks <-chan int) {
+func worker(tasks <-chan int, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for task := range tasks {
 		time.Sleep(time.Duration(task) * time.Millisecond)
 		atomic.AddUint64(&total, 1)
@@ -22,8 +24,10 @@ func TestLimiter(t *testing.T) {
 
 	tasks := make(chan int)
 
+	var wg sync.WaitGroup
 	for i := 0; i < 100; i++ {
-		go worker(tasks)
+		wg.Add(1)
+		go worker(tasks, &wg)
 	}
 
 	start := time.Now()
@@ -32,6 +36,7 @@ func TestLimiter(t *testing.T) {
 		tasks <- 1
 	}
 	close(tasks)
+	wg.Wait()
 	end := time.Now()
 	throughput := float64(total) / end.Sub(start).Seconds()
 	t.Logf("throughput = %f", throughput)
@@ -39,3 +44,33 @@ func TestLimiter(t *testing.T) {
 		t.Errorf("throughput is %f limit is %d", throughput, rate)
 	}
 }
+
+func TestArrivals(t *testing.T) {
+	// constant arrivals keep their schedule when caller falls behind
+	a := NewArrivals(1000, ArrivalConstant)
+	first := a.Wait()
+	time.Sleep(20 * time.Millisecond)
+	prev := first
+	for i := 1; i <= 10; i++ {
+		next := a.Wait()
+		if next.Sub(prev) != time.Millisecond {
+			t.Errorf("arrival %d is %v after previous one", i, next.Sub(prev))
+		}
+		prev = next
+	}
+	if time.Since(first) < 20*time.Millisecond || prev.Sub(first) != 10*time.Millisecond {
+		t.Errorf("arrivals are not on schedule")
+	}
+
+	// poisson inter-arrival times have mean of 1/rate
+	p := NewArrivals(1000, ArrivalPoisson)
+	var sum time.Duration
+	n := 100000
+	for i := 0; i < n; i++ {
+		sum += p.gap()
+	}
+	mean := sum / time.Duration(n)
+	if math.Abs(float64(mean-time.Millisecond))/float64(time.Millisecond) > 0.05 {
+		t.Errorf("mean inter-arrival time is %v, expected 1ms", mean)
+	}
+}
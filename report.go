@@ -0,0 +1,164 @@
+package paxi
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// timeline layout in pixels
+const (
+	timelineWidth  = 1000
+	timelineLabel  = 120
+	timelineRow    = 24
+	timelineHeight = 18
+)
+
+// WriteReport writes the anomalies found by heuristic linearizability checker into path.anomalies.txt,
+// and the timeline of operations of every key with anomalies into path.timeline.html.
+// It returns the number of anomaly reads, no file is written if there is none.
+func (h *History) WriteReport(path string) (int, error) {
+	anomalies := h.anomalies()
+	if len(anomalies) == 0 {
+		return 0, nil
+	}
+	keys := make([]int, 0, len(anomalies))
+	n := 0
+	for k, a := range anomalies {
+		keys = append(keys, k)
+		n += len(a)
+	}
+	sort.Ints(keys)
+
+	h.RLock()
+	defer h.RUnlock()
+	err := writeReport(path+".anomalies.txt", func(w io.Writer) {
+		for _, k := range keys {
+			h.reportKey(w, k, anomalies[k])
+		}
+	})
+	if err != nil {
+		return n, err
+	}
+	err = writeReport(path+".timeline.html", func(w io.Writer) {
+		fmt.Fprintln(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>Anomalies</title></head>\n<body style=\"font-family: monospace\">")
+		for _, k := range keys {
+			h.timeline(w, k, anomalies[k])
+		}
+		fmt.Fprintln(w, "</body>\n</html>")
+	})
+	return n, err
+}
+
+func writeReport(path string, write func(io.Writer)) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	write(w)
+	return w.Flush()
+}
+
+// reportKey writes every anomaly read of key with the cycle it forms, followed by all operations of key
+func (h *History) reportKey(w io.Writer, key int, anomalies []anomaly) {
+	fmt.Fprintf(w, "key=%d anomalies=%d\n", key, len(anomalies))
+	for _, a := range anomalies {
+		fmt.Fprintf(w, "  read %v\n", a.read)
+		for _, o := range a.cycle {
+			fmt.Fprintf(w, "    cycle %v\n", o)
+		}
+	}
+	ops := append([]*operation(nil), h.shard[key]...)
+	sort.Sort(byTime(ops))
+	fmt.Fprintln(w, "  operations")
+	for _, o := range ops {
+		fmt.Fprintf(w, "    %v\n", o)
+	}
+	fmt.Fprintln(w)
+}
+
+// timeline writes svg of operations of key with one row for each client session,
+// anomaly reads are red, operations in their cycles are outlined in orange,
+// failed operations are dashed and extend to the end of timeline
+func (h *History) timeline(w io.Writer, key int, anomalies []anomaly) {
+	ops := h.shard[key]
+	reads := make(map[*operation]bool)
+	cycles := make(map[*operation]bool)
+	for _, a := range anomalies {
+		reads[a.read] = true
+		for _, o := range a.cycle {
+			cycles[o] = true
+		}
+	}
+
+	// time range and rows of client sessions
+	min, max := int64(math.MaxInt64), int64(0)
+	rows := make(map[ID]int)
+	clients := make([]ID, 0)
+	for _, o := range ops {
+		if o.start < min {
+			min = o.start
+		}
+		if o.end != math.MaxInt64 && o.end > max {
+			max = o.end
+		}
+		if o.start > max {
+			max = o.start
+		}
+		if _, exists := rows[o.Client]; !exists {
+			rows[o.Client] = 0
+			clients = append(clients, o.Client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] < clients[j] })
+	for i, c := range clients {
+		rows[c] = i
+	}
+	span := float64(max - min)
+	if span == 0 {
+		span = 1
+	}
+	x := func(t int64) float64 {
+		if t > max {
+			t = max
+		}
+		return timelineLabel + float64(t-min)/span*(timelineWidth-timelineLabel)
+	}
+
+	fmt.Fprintf(w, "<h3>key=%d anomalies=%d</h3>\n", key, len(anomalies))
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-size=\"10\">\n", timelineWidth+10, len(clients)*timelineRow+20)
+	for i, c := range clients {
+		fmt.Fprintf(w, "<text x=\"0\" y=\"%d\">%s</text>\n", i*timelineRow+timelineHeight-5, html.EscapeString(string(c)))
+	}
+	for _, o := range ops {
+		fill, label := "#9ecae1", fmt.Sprintf("W %v", o.input)
+		if o.input == nil {
+			fill, label = "#a1d99b", fmt.Sprintf("R %v", o.output)
+		}
+		if reads[o] {
+			fill = "#fb6a4a"
+		}
+		stroke := "#636363"
+		if cycles[o] {
+			stroke = "#fd8d3c"
+		}
+		dash := ""
+		if o.indeterminate() {
+			dash = " stroke-dasharray=\"4,2\""
+		}
+		x1, x2 := x(o.start), x(o.end)
+		y := rows[o.Client] * timelineRow
+		fmt.Fprintf(w, "<g><title>%s</title>", html.EscapeString(o.String()))
+		fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\" stroke=\"%s\"%s/>", x1, y, math.Max(x2-x1, 1), timelineHeight, fill, stroke, dash)
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%d\">%s</text></g>\n", x1+2, y+timelineHeight-5, html.EscapeString(label))
+	}
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\">%d ns</text>\n", timelineLabel, len(clients)*timelineRow+15, min)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%d ns</text>\n", timelineWidth, len(clients)*timelineRow+15, max)
+	fmt.Fprintln(w, "</svg>")
+}
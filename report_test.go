@@ -0,0 +1,63 @@
+package paxi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	h := NewHistory()
+	h.Add(1, 1, nil, 0, 10, client(1))
+	h.Add(1, nil, 1, 20, 30, client(2))
+	h.Add(2, 1, nil, 0, 10, client(1))
+	h.Add(2, 2, nil, 20, 30, client(1))
+	h.Add(2, nil, 1, 40, 50, client(2))
+
+	n, err := h.WriteReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("reported %d anomalies, expected 1", n)
+	}
+	report, err := ioutil.ReadFile(path + ".anomalies.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "key=2 anomalies=1") || !strings.Contains(string(report), "cycle") || strings.Contains(string(report), "key=1") {
+		t.Errorf("report is\n%s", report)
+	}
+	timeline, err := ioutil.ReadFile(path + ".timeline.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(timeline), "<svg") != 1 || strings.Count(string(timeline), "<rect") != 3 {
+		t.Errorf("timeline is\n%s", timeline)
+	}
+
+	// history is not changed by checker
+	if o := h.shard[2][1]; o.end != 30 {
+		t.Errorf("write end time changed to %d", o.end)
+	}
+
+	// nothing is written for linearizable history
+	os.Remove(path + ".anomalies.txt")
+	h = NewHistory()
+	h.Add(1, 1, nil, 0, 10)
+	if n, err := h.WriteReport(path); n != 0 || err != nil {
+		t.Errorf("linearizable history reported %d anomalies, %v", n, err)
+	}
+	if _, err := os.Stat(path + ".anomalies.txt"); !os.IsNotExist(err) {
+		t.Error("report written for linearizable history")
+	}
+}
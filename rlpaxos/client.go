@@ -2,14 +2,18 @@ package paxos2bro
 
 import (
 	"strconv"
+	"sync"
 
 	"github.com/ailidani/paxi"
 	"github.com/ailidani/paxi/log"
 )
 
 // Client overwrites read operation for Paxos
+// Client is safe for concurrent use by multiple goroutines
 type Client struct {
 	*paxi.HTTPClient
+
+	mu     sync.RWMutex // protects ballot
 	ballot paxi.Ballot
 }
 
@@ -25,8 +29,6 @@ func NewClient(id paxi.ID) *Client {
 // (2) read from leader with current ballot number
 // (3) read from quorum of replicas with barrier
 func (c *Client) Get(key paxi.Key) (paxi.Value, error) {
-
-	c.HTTPClient.CID++
 	switch *read2bro {
 	case "leader":
 		return c.readLeader(key)
@@ -44,7 +46,6 @@ func (c *Client) Get(key paxi.Key) (paxi.Value, error) {
 }
 
 func (c *Client) Put(key paxi.Key, value paxi.Value) error {
-	c.HTTPClient.CID++
 	_, _, err := c.RESTPut(c.ID, key, value)
 	// if err == nil {
 	// 	b := paxi.NewBallotFromString(meta[HTTPHeaderBallot])
@@ -57,32 +58,43 @@ func (c *Client) Put(key paxi.Key, value paxi.Value) error {
 }
 
 func (c *Client) readLeader(key paxi.Key) (paxi.Value, error) {
-	if c.ballot == 0 {
+	ballot := c.getBallot()
+	if ballot == 0 {
 		v, meta, err := c.HTTPClient.RESTGet(c.ID, key)
-		c.ballot = paxi.NewBallotFromString(meta[HTTPHeaderBallot])
+		c.updateBallot(paxi.NewBallotFromString(meta[HTTPHeaderBallot]))
 		return v, err
 	}
 	// check ballot number
-	v, meta, err := c.HTTPClient.RESTGet(c.ballot.ID(), key)
-	b := paxi.NewBallotFromString(meta[HTTPHeaderBallot])
-	if b > c.ballot {
-		c.ballot = b
-	}
+	v, meta, err := c.HTTPClient.RESTGet(ballot.ID(), key)
+	c.updateBallot(paxi.NewBallotFromString(meta[HTTPHeaderBallot]))
 	return v, err
 }
 func (c *Client) readLocal(key paxi.Key) (paxi.Value, error) {
-	if c.ballot == 0 {
+	ballot := c.getBallot()
+	if ballot == 0 {
 		v, meta, err := c.HTTPClient.RESTGet(c.ID, key)
-		c.ballot = paxi.NewBallotFromString(meta[HTTPHeaderBallot])
+		c.updateBallot(paxi.NewBallotFromString(meta[HTTPHeaderBallot]))
 		return v, err
 	}
 	// check ballot number
-	v, meta, err := c.HTTPClient.RESTGet(c.ballot.ID(), key)
-	b := paxi.NewBallotFromString(meta[HTTPHeaderBallot])
+	v, meta, err := c.HTTPClient.RESTGet(ballot.ID(), key)
+	c.updateBallot(paxi.NewBallotFromString(meta[HTTPHeaderBallot]))
+	return v, err
+}
+
+func (c *Client) getBallot() paxi.Ballot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ballot
+}
+
+// updateBallot keeps the highest ballot seen from replicas
+func (c *Client) updateBallot(b paxi.Ballot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if b > c.ballot {
 		c.ballot = b
 	}
-	return v, err
 }
 func (c *Client) readQuorum(key paxi.Key) (paxi.Value, error) {
 	majority := c.N/2 + 1
@@ -501,7 +501,7 @@ func (p *Paxos) exec(s int) {
 			return
 		}
 		// Execute the command
-		value := p.Execute(entry.Command)
+		value := p.ExecuteAt(s, entry.Command)
 		entry.Status = Execute
 		log.Debugf("Replica %s executes slot %d out-of-order", p.ID(), s)
 
@@ -537,7 +537,7 @@ func (p *Paxos) exec(s int) {
 			p.execute++
 			break
 		}
-		value := p.Execute(entry.Command)
+		value := p.ExecuteAt(p.execute, entry.Command)
 		entry.Status = Execute
 		p.execute++
 		log.Debugf("Replica %s executes slot %d in order,next slot is %d", p.ID(), p.execute-1, p.execute)
@@ -111,5 +111,5 @@ func (r *Replica) readInProgress(m paxi.Request) (paxi.Value, int, string, bool)
 	// 	}
 	// }
 	// not in log
-	return r.Node.Execute(m.Command), -1, "", false
+	return r.Node.Get(m.Command.Key), -1, "", false
 }
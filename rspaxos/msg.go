@@ -0,0 +1,145 @@
+package rspaxos
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+)
+
+func init() {
+	gob.Register(P1a{})
+	gob.Register(P1b{})
+	gob.Register(P2a{})
+	gob.Register(P2b{})
+	gob.Register(P3{})
+	gob.Register(ReadShard{})
+	gob.Register(ShardReply{})
+}
+
+// Shard is one erasure coded piece of a written value
+type Shard struct {
+	Slot  int // slot of the write, -1 if key is never written
+	Index int // shard index, -1 if replica committed the write without receiving its shard
+	Size  int // size of the whole value
+	Data  []byte
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("Shard {s=%d i=%d size=%d len=%d}", s.Slot, s.Index, s.Size, len(s.Data))
+}
+
+// Value encodes shard as the value kept in replica database
+func (s Shard) Value() paxi.Value {
+	b := make([]byte, 3*binary.MaxVarintLen64, 3*binary.MaxVarintLen64+len(s.Data))
+	n := binary.PutVarint(b, int64(s.Slot))
+	n += binary.PutVarint(b[n:], int64(s.Index))
+	n += binary.PutVarint(b[n:], int64(s.Size))
+	return append(b[:n], s.Data...)
+}
+
+// decodeShard decodes shard from value in replica database
+func decodeShard(v paxi.Value) (Shard, bool) {
+	var x [3]int64
+	for i := range x {
+		n := 0
+		x[i], n = binary.Varint(v)
+		if n <= 0 {
+			return Shard{}, false
+		}
+		v = v[n:]
+	}
+	return Shard{Slot: int(x[0]), Index: int(x[1]), Size: int(x[2]), Data: v}, true
+}
+
+// P1a prepare message
+type P1a struct {
+	Ballot paxi.Ballot
+}
+
+func (m P1a) String() string {
+	return fmt.Sprintf("P1a {b=%v}", m.Ballot)
+}
+
+// Accepted is an uncommitted entry reported in promise, with only the shard of the acceptor
+type Accepted struct {
+	Ballot  paxi.Ballot
+	Command paxi.Command // command without value
+	Write   bool
+	Shard   *Shard
+}
+
+func (a Accepted) String() string {
+	return fmt.Sprintf("b=%v cmd=%v write=%t shard=%v", a.Ballot, a.Command, a.Write, a.Shard)
+}
+
+// P1b promise message
+type P1b struct {
+	Ballot paxi.Ballot
+	ID     paxi.ID          // from node id
+	Log    map[int]Accepted // uncommitted logs
+}
+
+func (m P1b) String() string {
+	return fmt.Sprintf("P1b {b=%v id=%s log=%v}", m.Ballot, m.ID, m.Log)
+}
+
+// P2a accept message carrying only the shard of receiving acceptor
+type P2a struct {
+	Ballot  paxi.Ballot
+	Slot    int
+	Command paxi.Command // command without value
+	Write   bool
+	Shard   *Shard
+}
+
+func (m P2a) String() string {
+	return fmt.Sprintf("P2a {b=%v s=%d cmd=%v shard=%v}", m.Ballot, m.Slot, m.Command, m.Shard)
+}
+
+// P2b accepted message
+type P2b struct {
+	Ballot paxi.Ballot
+	ID     paxi.ID // from node id
+	Slot   int
+}
+
+func (m P2b) String() string {
+	return fmt.Sprintf("P2b {b=%v id=%s s=%d}", m.Ballot, m.ID, m.Slot)
+}
+
+// P3 commit message
+type P3 struct {
+	Ballot  paxi.Ballot
+	Slot    int
+	Command paxi.Command // command without value
+	Write   bool
+}
+
+func (m P3) String() string {
+	return fmt.Sprintf("P3 {b=%v s=%d cmd=%v}", m.Ballot, m.Slot, m.Command)
+}
+
+// ReadShard asks a replica for its shard of key once it executed slot
+type ReadShard struct {
+	ID   paxi.ID // from node id
+	Slot int
+	Key  paxi.Key
+}
+
+func (m ReadShard) String() string {
+	return fmt.Sprintf("ReadShard {id=%s s=%d key=%v}", m.ID, m.Slot, m.Key)
+}
+
+// ShardReply replies the current shard of key
+type ShardReply struct {
+	ID    paxi.ID // from node id
+	Slot  int     // slot of read
+	Key   paxi.Key
+	Shard Shard
+}
+
+func (m ShardReply) String() string {
+	return fmt.Sprintf("ShardReply {id=%s s=%d key=%v shard=%v}", m.ID, m.Slot, m.Key, m.Shard)
+}
@@ -0,0 +1,43 @@
+package rspaxos
+
+import (
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// Replica for one RS-Paxos instance
+type Replica struct {
+	paxi.Node
+	*RSPaxos
+}
+
+// NewReplica generates new RS-Paxos replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new RS-Paxos replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.RSPaxos = NewRSPaxos(r)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(P1a{}, r.HandleP1a)
+	r.Register(P1b{}, r.HandleP1b)
+	r.Register(P2a{}, r.HandleP2a)
+	r.Register(P2b{}, r.HandleP2b)
+	r.Register(P3{}, r.HandleP3)
+	r.Register(ReadShard{}, r.HandleReadShard)
+	r.Register(ShardReply{}, r.HandleShardReply)
+	return r
+}
+
+// handleRequest orders reads and writes in log, reads are served by the leader reconstructing values from shards
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+	if r.RSPaxos.IsLeader() || r.RSPaxos.Ballot() == 0 {
+		r.RSPaxos.HandleRequest(m)
+	} else {
+		go r.Forward(r.RSPaxos.Leader(), m)
+	}
+}
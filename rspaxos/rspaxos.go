@@ -0,0 +1,554 @@
+package rspaxos
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+)
+
+// entry in log, a replica keeps only its own shard of written value
+type entry struct {
+	ballot  paxi.Ballot
+	command paxi.Command // command without value
+	write   bool
+	shard   *Shard
+	commit  bool
+	request *paxi.Request
+	quorum  *paxi.Quorum
+}
+
+// read is a pending read collecting shards of key from replicas
+type read struct {
+	request *paxi.Request
+	key     paxi.Key
+	shards  map[paxi.ID]Shard
+}
+
+// RSPaxos instance replicates erasure coded values,
+// the i-th replica in sorted order of ids stores the i-th shard of every written value
+type RSPaxos struct {
+	paxi.Node
+
+	ids   []paxi.ID // replicas sorted by id
+	index int       // shard index of this replica
+	x     int       // number of data shards
+	k     int       // number of parity shards
+
+	log     map[int]*entry
+	execute int         // next execute slot number
+	active  bool        // active leader
+	ballot  paxi.Ballot // highest ballot number
+	slot    int         // highest slot number
+
+	quorum    *paxi.Quorum        // phase 1 quorum
+	requests  []*paxi.Request     // phase 1 pending requests
+	recovered map[int][]Accepted  // phase 1 accepted entries from acceptors
+	reads     map[int]*read       // pending reads by slot
+	waiting   map[int][]ReadShard // shard requests of slots not executed yet
+
+	Q1 func(*paxi.Quorum) bool
+	Q2 func(*paxi.Quorum) bool
+}
+
+// NewRSPaxos creates new RS-Paxos instance, shard layout is given by erasure_data and erasure_parity in config
+func NewRSPaxos(n paxi.Node, options ...func(*RSPaxos)) *RSPaxos {
+	ids := paxi.GetConfig().IDs()
+	sort.Sort(paxi.IDs(ids))
+	p := &RSPaxos{
+		Node:      n,
+		ids:       ids,
+		index:     -1,
+		x:         paxi.GetConfig().ErasureData,
+		k:         paxi.GetConfig().ErasureParity,
+		log:       make(map[int]*entry, paxi.GetConfig().BufferSize),
+		slot:      -1,
+		quorum:    paxi.NewQuorum(),
+		requests:  make([]*paxi.Request, 0),
+		recovered: make(map[int][]Accepted),
+		reads:     make(map[int]*read),
+		waiting:   make(map[int][]ReadShard),
+		Q1:        func(q *paxi.Quorum) bool { return q.MajorityX() },
+		Q2:        func(q *paxi.Quorum) bool { return q.MajorityX() },
+	}
+	for i, id := range ids {
+		if id == n.ID() {
+			p.index = i
+		}
+	}
+	if p.x <= 0 || p.x+p.k != len(ids) {
+		log.Fatalf("erasure code of %d data and %d parity shards does not match %d replicas", p.x, p.k, len(ids))
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+// IsLeader indecates if this node is current leader
+func (p *RSPaxos) IsLeader() bool {
+	return p.active || p.ballot.ID() == p.ID()
+}
+
+// Leader returns leader id of the current ballot
+func (p *RSPaxos) Leader() paxi.ID {
+	return p.ballot.ID()
+}
+
+// Ballot returns current ballot
+func (p *RSPaxos) Ballot() paxi.Ballot {
+	return p.ballot
+}
+
+// HandleRequest handles request and start phase 1 or phase 2
+func (p *RSPaxos) HandleRequest(r paxi.Request) {
+	if !p.active {
+		p.requests = append(p.requests, &r)
+		// current phase 1 pending
+		if p.ballot.ID() != p.ID() {
+			p.P1a()
+		}
+	} else {
+		p.P2a(&r)
+	}
+}
+
+// P1a starts phase 1 prepare
+func (p *RSPaxos) P1a() {
+	if p.active {
+		return
+	}
+	p.ballot.Next(p.ID())
+	p.quorum.Reset()
+	p.quorum.ACK(p.ID())
+	p.recovered = make(map[int][]Accepted)
+	p.Broadcast(P1a{Ballot: p.ballot})
+}
+
+// P2a starts phase 2 accept of request in next slot
+func (p *RSPaxos) P2a(r *paxi.Request) {
+	p.slot++
+	p.propose(p.slot, r.Command, r.Command.IsWrite(), r.Command.Value, r)
+}
+
+// propose splits value of command into shards and sends each acceptor only its own shard
+func (p *RSPaxos) propose(s int, c paxi.Command, write bool, value paxi.Value, r *paxi.Request) {
+	c.Value = nil
+	var shards [][]byte
+	if write {
+		var err error
+		shards, err = paxi.Splitvalue(value, p.x, p.k)
+		if err != nil {
+			log.Error(err)
+			if r != nil {
+				r.Reply(paxi.Reply{Command: r.Command, Err: err})
+			}
+			// fill the slot with empty command
+			c, write, r = paxi.Command{}, false, nil
+		}
+	}
+
+	e := &entry{
+		ballot:  p.ballot,
+		command: c,
+		write:   write,
+		request: r,
+		quorum:  paxi.NewQuorum(),
+	}
+	if write {
+		e.shard = &Shard{Slot: s, Index: p.index, Size: len(value), Data: shards[p.index]}
+	}
+	p.log[s] = e
+	e.quorum.ACK(p.ID())
+
+	for i, id := range p.ids {
+		if id == p.ID() {
+			continue
+		}
+		m := P2a{
+			Ballot:  p.ballot,
+			Slot:    s,
+			Command: c,
+			Write:   write,
+		}
+		if write {
+			m.Shard = &Shard{Slot: s, Index: i, Size: len(value), Data: shards[i]}
+		}
+		p.Send(id, m)
+	}
+}
+
+// HandleP1a handles P1a message
+func (p *RSPaxos) HandleP1a(m P1a) {
+	// new leader
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		p.forward()
+	}
+
+	l := make(map[int]Accepted)
+	for s := p.execute; s <= p.slot; s++ {
+		e, exists := p.log[s]
+		if !exists || e.commit {
+			continue
+		}
+		l[s] = Accepted{Ballot: e.ballot, Command: e.command, Write: e.write, Shard: e.shard}
+	}
+
+	p.Send(m.Ballot.ID(), P1b{
+		Ballot: p.ballot,
+		ID:     p.ID(),
+		Log:    l,
+	})
+}
+
+// HandleP1b handles P1b message
+func (p *RSPaxos) HandleP1b(m P1b) {
+	// old message
+	if m.Ballot < p.ballot || p.active {
+		return
+	}
+
+	// reject message
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		p.forward()
+		return
+	}
+
+	// ack message
+	if m.Ballot.ID() == p.ID() && m.Ballot == p.ballot {
+		for s, a := range m.Log {
+			p.slot = paxi.Max(p.slot, s)
+			p.recovered[s] = append(p.recovered[s], a)
+		}
+		p.quorum.ACK(m.ID)
+		if p.Q1(p.quorum) {
+			p.active = true
+			p.recover()
+			for _, r := range p.requests {
+				p.P2a(r)
+			}
+			p.requests = make([]*paxi.Request, 0)
+		}
+	}
+}
+
+// recover proposes again every uncommitted slot with the value recovered from phase 1 quorum
+func (p *RSPaxos) recover() {
+	for s := p.execute; s <= p.slot; s++ {
+		e, exists := p.log[s]
+		if exists && e.commit {
+			p.Broadcast(P3{Ballot: p.ballot, Slot: s, Command: e.command, Write: e.write})
+			continue
+		}
+		accepted := p.recovered[s]
+		if exists {
+			accepted = append(accepted, Accepted{Ballot: e.ballot, Command: e.command, Write: e.write, Shard: e.shard})
+		}
+		c, write, value := p.choose(accepted)
+		var r *paxi.Request
+		if exists && e.request != nil {
+			if e.command.Equal(c) {
+				r = e.request
+			} else {
+				p.requests = append(p.requests, e.request)
+			}
+		}
+		p.propose(s, c, write, value, r)
+	}
+	p.recovered = make(map[int][]Accepted)
+}
+
+// choose returns the command of highest ballot that is read or has at least x shards to recover its value,
+// the slot cannot be chosen by any previous ballot otherwise and an empty command is returned
+func (p *RSPaxos) choose(accepted []Accepted) (paxi.Command, bool, paxi.Value) {
+	sort.Slice(accepted, func(i, j int) bool { return accepted[i].Ballot > accepted[j].Ballot })
+	for i := 0; i < len(accepted); {
+		a := accepted[i]
+		if !a.Write {
+			return a.Command, false, nil
+		}
+		shards := make([][]byte, p.x+p.k)
+		present := make([]bool, p.x+p.k)
+		n, size := 0, 0
+		for ; i < len(accepted) && accepted[i].Ballot == a.Ballot; i++ {
+			s := accepted[i].Shard
+			if s == nil || s.Index < 0 || s.Index >= len(shards) || present[s.Index] {
+				continue
+			}
+			shards[s.Index] = s.Data
+			present[s.Index] = true
+			size = s.Size
+			n++
+		}
+		if n < p.x {
+			continue
+		}
+		v, err := paxi.Recovervalue(shards, p.x, p.k, size)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		return a.Command, true, v
+	}
+	return paxi.Command{}, false, nil
+}
+
+// HandleP2a handles P2a message
+func (p *RSPaxos) HandleP2a(m P2a) {
+	if m.Ballot >= p.ballot {
+		if m.Ballot > p.ballot {
+			p.ballot = m.Ballot
+			p.active = false
+			p.forward()
+		}
+		p.slot = paxi.Max(p.slot, m.Slot)
+		e, exists := p.log[m.Slot]
+		if exists && e.commit {
+			if e.shard == nil && e.command.Equal(m.Command) {
+				e.shard = m.Shard
+			}
+		} else if m.Slot >= p.execute {
+			var r *paxi.Request
+			if exists && e.request != nil {
+				if e.command.Equal(m.Command) {
+					r = e.request
+				} else {
+					p.Forward(m.Ballot.ID(), *e.request)
+				}
+			}
+			p.log[m.Slot] = &entry{
+				ballot:  m.Ballot,
+				command: m.Command,
+				write:   m.Write,
+				shard:   m.Shard,
+				request: r,
+			}
+		}
+	}
+
+	p.Send(m.Ballot.ID(), P2b{
+		Ballot: p.ballot,
+		ID:     p.ID(),
+		Slot:   m.Slot,
+	})
+}
+
+// HandleP2b handles P2b message
+func (p *RSPaxos) HandleP2b(m P2b) {
+	// reject message
+	if m.Ballot > p.ballot {
+		p.ballot = m.Ballot
+		p.active = false
+		p.forward()
+		return
+	}
+
+	e, exists := p.log[m.Slot]
+	if m.Slot < p.execute || !exists || e.commit || e.quorum == nil {
+		return
+	}
+
+	if m.Ballot == e.ballot && e.ballot == p.ballot {
+		e.quorum.ACK(m.ID)
+		if p.Q2(e.quorum) {
+			e.commit = true
+			p.Broadcast(P3{
+				Ballot:  m.Ballot,
+				Slot:    m.Slot,
+				Command: e.command,
+				Write:   e.write,
+			})
+			p.exec()
+		}
+	}
+}
+
+// HandleP3 handles phase 3 commit message
+func (p *RSPaxos) HandleP3(m P3) {
+	p.slot = paxi.Max(p.slot, m.Slot)
+	if m.Slot < p.execute {
+		return
+	}
+	e, exists := p.log[m.Slot]
+	if !exists {
+		e = new(entry)
+		p.log[m.Slot] = e
+	}
+	// shard of a different command is useless
+	if !e.command.Equal(m.Command) || e.write != m.Write {
+		if e.request != nil {
+			p.Forward(m.Ballot.ID(), *e.request)
+		}
+		e.shard = nil
+		e.request = nil
+	}
+	e.ballot = m.Ballot
+	e.command = m.Command
+	e.write = m.Write
+	e.commit = true
+	p.exec()
+}
+
+// exec executes committed slots in order,
+// database of replica keeps the encoded shard of latest write per key
+func (p *RSPaxos) exec() {
+	for {
+		e, exists := p.log[p.execute]
+		if !exists || !e.commit {
+			break
+		}
+		p.apply(p.execute, e)
+		delete(p.log, p.execute)
+		p.execute++
+		// shard requests waiting for the executed slot
+		waiting := p.waiting[p.execute-1]
+		delete(p.waiting, p.execute-1)
+		for _, m := range waiting {
+			p.HandleReadShard(m)
+		}
+	}
+}
+
+func (p *RSPaxos) apply(s int, e *entry) {
+	if e.command.Empty() || !e.write {
+		// slot is executed without changing shards, reads collect shards from replicas
+		p.ExecuteAt(s, paxi.Command{})
+		if !e.command.Empty() && e.request != nil {
+			p.read(s, e)
+		}
+		return
+	}
+	shard := Shard{Slot: s, Index: -1}
+	if e.shard != nil {
+		shard = *e.shard
+		shard.Slot = s
+	}
+	c := e.command
+	c.Value = shard.Value()
+	p.ExecuteAt(s, c)
+	if e.request != nil {
+		e.request.Reply(paxi.Reply{
+			Command:   e.request.Command,
+			Timestamp: time.Now().Unix(),
+		})
+		e.request = nil
+	}
+}
+
+// read starts collecting shards of key as of executed slot s from replicas
+func (p *RSPaxos) read(s int, e *entry) {
+	p.reads[s] = &read{
+		request: e.request,
+		key:     e.command.Key,
+		shards:  make(map[paxi.ID]Shard),
+	}
+	e.request = nil
+	p.Broadcast(ReadShard{ID: p.ID(), Slot: s, Key: e.command.Key})
+	p.HandleShardReply(ShardReply{ID: p.ID(), Slot: s, Key: e.command.Key, Shard: p.shard(e.command.Key)})
+}
+
+// shard returns current shard of key in database
+func (p *RSPaxos) shard(k paxi.Key) Shard {
+	v := p.Get(k)
+	if v == nil {
+		return Shard{Slot: -1, Index: p.index}
+	}
+	s, ok := decodeShard(v)
+	if !ok {
+		log.Errorf("replica %s has corrupted shard of key %v", p.ID(), k)
+		return Shard{Slot: -1, Index: -1}
+	}
+	return s
+}
+
+// HandleReadShard replies the current shard of key after slot of read is executed
+func (p *RSPaxos) HandleReadShard(m ReadShard) {
+	if m.Slot >= p.execute {
+		p.waiting[m.Slot] = append(p.waiting[m.Slot], m)
+		return
+	}
+	p.Send(m.ID, ShardReply{
+		ID:    p.ID(),
+		Slot:  m.Slot,
+		Key:   m.Key,
+		Shard: p.shard(m.Key),
+	})
+}
+
+// HandleShardReply reconstructs value of pending read once x replicas reply shards of the same write.
+// Every replica replies after executing the read slot, so any such write is linearizable for the read.
+func (p *RSPaxos) HandleShardReply(m ShardReply) {
+	r, exists := p.reads[m.Slot]
+	if !exists {
+		return
+	}
+	r.shards[m.ID] = m.Shard
+	if v, ok := p.reconstruct(r.shards); ok {
+		r.request.Reply(paxi.Reply{
+			Command:   r.request.Command,
+			Value:     v,
+			Timestamp: time.Now().Unix(),
+		})
+		delete(p.reads, m.Slot)
+		return
+	}
+	// replicas hold shards of different writes, ask again
+	if len(r.shards) == len(p.ids) {
+		r.shards = make(map[paxi.ID]Shard)
+		p.Broadcast(ReadShard{ID: p.ID(), Slot: m.Slot, Key: r.key})
+		p.HandleShardReply(ShardReply{ID: p.ID(), Slot: m.Slot, Key: r.key, Shard: p.shard(r.key)})
+	}
+}
+
+// reconstruct returns the value of the latest write that has at least x shards,
+// shards are visited in order of replica ids so that the result does not depend on map order
+func (p *RSPaxos) reconstruct(shards map[paxi.ID]Shard) (paxi.Value, bool) {
+	writes := make(map[int][]Shard)
+	slots := make([]int, 0)
+	for _, id := range p.ids {
+		s, exists := shards[id]
+		if !exists || s.Index < 0 || s.Index >= p.x+p.k {
+			continue
+		}
+		if _, exists := writes[s.Slot]; !exists {
+			slots = append(slots, s.Slot)
+		}
+		writes[s.Slot] = append(writes[s.Slot], s)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(slots)))
+	for _, slot := range slots {
+		w := writes[slot]
+		if len(w) < p.x {
+			continue
+		}
+		// key is never written
+		if slot < 0 {
+			return nil, true
+		}
+		data := make([][]byte, p.x+p.k)
+		for _, s := range w {
+			data[s.Index] = s.Data
+		}
+		v, err := paxi.Recovervalue(data, p.x, p.k, w[0].Size)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+func (p *RSPaxos) forward() {
+	for _, m := range p.requests {
+		p.Forward(p.ballot.ID(), *m)
+	}
+	p.requests = make([]*paxi.Request, 0)
+}
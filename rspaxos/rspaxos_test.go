@@ -0,0 +1,124 @@
+package rspaxos
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+func TestShardValue(t *testing.T) {
+	s := Shard{Slot: 42, Index: -1, Size: 7, Data: []byte("abc")}
+	d, ok := decodeShard(s.Value())
+	if !ok || d.Slot != s.Slot || d.Index != s.Index || d.Size != s.Size || !bytes.Equal(d.Data, s.Data) {
+		t.Errorf("decodeShard(%v) = %v, %t", s, d, ok)
+	}
+}
+
+func TestChoose(t *testing.T) {
+	p := &RSPaxos{x: 3, k: 2}
+	value := paxi.Value("erasure coded value")
+	shards, err := paxi.Splitvalue(value, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1 := paxi.NewBallot(1, "1.1")
+	b2 := paxi.NewBallot(2, "1.2")
+	c1 := paxi.Command{Key: 1, ClientID: "1.1", CommandID: 1}
+	c2 := paxi.Command{Key: 1, ClientID: "1.2", CommandID: 1}
+	shard := func(i int) *Shard { return &Shard{Index: i, Size: len(value), Data: shards[i]} }
+
+	// highest ballot has too few shards to be chosen, fall back to lower ballot
+	accepted := []Accepted{
+		{Ballot: b2, Command: c2, Write: true, Shard: shard(0)},
+		{Ballot: b1, Command: c1, Write: true, Shard: shard(1)},
+		{Ballot: b1, Command: c1, Write: true, Shard: shard(3)},
+		{Ballot: b1, Command: c1, Write: true, Shard: shard(4)},
+	}
+	c, write, v := p.choose(accepted)
+	if !c.Equal(c1) || !write || !bytes.Equal(v, value) {
+		t.Errorf("choose() = %v %t %s, want %v true %s", c, write, v, c1, value)
+	}
+
+	// nothing can be recovered
+	c, write, _ = p.choose(accepted[:2])
+	if !c.Empty() || write {
+		t.Errorf("choose() = %v %t, want empty command", c, write)
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	p := &RSPaxos{ids: []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}, x: 2, k: 3}
+	v1, v2 := paxi.Value("first"), paxi.Value("second")
+	s1, err := paxi.Splitvalue(v1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := paxi.Splitvalue(v2, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both writes can be reconstructed, the latest one is returned every time
+	shards := map[paxi.ID]Shard{
+		"1.1": {Slot: 1, Index: 0, Size: len(v1), Data: s1[0]},
+		"1.2": {Slot: 1, Index: 1, Size: len(v1), Data: s1[1]},
+		"1.3": {Slot: 2, Index: 2, Size: len(v2), Data: s2[2]},
+		"1.4": {Slot: 2, Index: 3, Size: len(v2), Data: s2[3]},
+		"1.5": {Slot: 2, Index: 4, Size: len(v2), Data: s2[4]},
+	}
+	for i := 0; i < 20; i++ {
+		if v, ok := p.reconstruct(shards); !ok || !bytes.Equal(v, v2) {
+			t.Fatalf("reconstruct() = %s %t, want %s", v, ok, v2)
+		}
+	}
+}
+
+func TestRSPaxos(t *testing.T) {
+	// five replicas for the default erasure code of 3 data and 2 parity shards
+	ids := []paxi.ID{"1.1", "1.2", "1.3", "1.4", "1.5"}
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		newReplica(nodes[id]).Run()
+	}
+
+	request := func(id paxi.ID, c paxi.Command) paxi.Value {
+		return nodes[id].Call(t, c).Value
+	}
+	check := func(from, to int) {
+		for i := from; i < to; i++ {
+			id := ids[i%len(ids)]
+			if nodes[id].Failed() {
+				id = ids[0]
+			}
+			if v := request(id, paxi.Command{Key: paxi.Key(i)}); string(v) != "value "+strconv.Itoa(i) {
+				t.Errorf("read of key %d from %s = %q", i, id, v)
+			}
+		}
+	}
+
+	// values are reconstructed from shards of replicas
+	for i := 1; i <= 10; i++ {
+		request(ids[i%len(ids)], paxi.Command{Key: paxi.Key(i), Value: paxi.Value("value " + strconv.Itoa(i))})
+	}
+	check(1, 11)
+	if v := request("1.2", paxi.Command{Key: 100}); v != nil {
+		t.Errorf("read of key never written = %q", v)
+	}
+	for _, id := range ids {
+		if s, ok := decodeShard(nodes[id].Get(10)); !ok || s.Size != len("value 10") || len(s.Data) >= s.Size {
+			t.Errorf("replica %s keeps shard %v of key 10", id, s)
+		}
+	}
+
+	// one replica fails, the rest still commit writes and reconstruct values
+	nodes["1.5"].Fail(true)
+	for i := 11; i <= 15; i++ {
+		request(ids[i%4], paxi.Command{Key: paxi.Key(i), Value: paxi.Value("value " + strconv.Itoa(i))})
+	}
+	check(1, 16)
+}
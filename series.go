@@ -0,0 +1,185 @@
+package paxi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Series is time series of throughput and latency of reads and writes in fixed intervals of benchmark,
+// every operation is counted in the interval it completes.
+// Only the latest two intervals keep latency histograms, older ones are closed and only their summaries are kept,
+// so memory grows with number of intervals but not with throughput.
+type Series struct {
+	sync.Mutex
+	interval time.Duration
+	closed   [][2]Interval // summaries of closed intervals
+	open     []*bucket     // buckets of intervals after closed ones
+}
+
+// bucket holds latency histograms of operations completed in one interval by operation type
+type bucket struct {
+	latency [2]*Histogram
+	errors  [2]int
+}
+
+func newBucket() *bucket {
+	return &bucket{latency: [2]*Histogram{NewLatencyHistogram(), NewLatencyHistogram()}}
+}
+
+// Interval is the summary of operations of one type completed in one interval, latencies are in milliseconds
+type Interval struct {
+	Time       float64 `json:"time"` // start of interval in seconds since start of benchmark
+	Type       string  `json:"type"`
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	Throughput float64 `json:"throughput"` // operations per second
+	Mean       float64 `json:"mean"`
+	P50        float64 `json:"p50"`
+	P95        float64 `json:"p95"`
+	P99        float64 `json:"p99"`
+	Max        float64 `json:"max"`
+}
+
+func (i Interval) String() string {
+	return fmt.Sprintf("%.1fs %s throughput=%.1f errors=%d mean=%.3f p50=%.3f p95=%.3f p99=%.3f max=%.3f",
+		i.Time, i.Type, i.Throughput, i.Errors, i.Mean, i.P50, i.P95, i.P99, i.Max)
+}
+
+// NewSeries creates new time series of given interval
+func NewSeries(interval time.Duration) *Series {
+	return &Series{
+		interval: interval,
+		closed:   make([][2]Interval, 0),
+		open:     make([]*bucket, 0),
+	}
+}
+
+// Add adds operation of type t completed at time end since start of benchmark
+func (s *Series) Add(end time.Duration, t OpType, latency time.Duration, err error) {
+	s.Lock()
+	defer s.Unlock()
+	i := int(end / s.interval)
+	for len(s.closed)+len(s.open) <= i {
+		s.open = append(s.open, newBucket())
+	}
+	for len(s.open) > 2 {
+		s.closed = append(s.closed, s.summary(len(s.closed), s.open[0]))
+		s.open[0] = nil
+		s.open = s.open[1:]
+	}
+
+	if i < len(s.closed) {
+		// operation completed too late for latency histogram of its interval, only counted
+		c := &s.closed[i][t]
+		if err != nil {
+			c.Errors++
+		} else {
+			c.Count++
+			c.Throughput = float64(c.Count) / s.interval.Seconds()
+		}
+		return
+	}
+	b := s.open[i-len(s.closed)]
+	if err != nil {
+		b.errors[t]++
+		return
+	}
+	b.latency[t].Record(latency)
+}
+
+// Len returns number of intervals
+func (s *Series) Len() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.closed) + len(s.open)
+}
+
+// Interval returns summary of reads and writes in i-th interval
+func (s *Series) Interval(i int) []Interval {
+	s.Lock()
+	defer s.Unlock()
+	if i < 0 || i >= len(s.closed)+len(s.open) {
+		return nil
+	}
+	if i < len(s.closed) {
+		return append([]Interval(nil), s.closed[i][:]...)
+	}
+	summary := s.summary(i, s.open[i-len(s.closed)])
+	return summary[:]
+}
+
+// Intervals returns summary of reads and writes in every interval
+func (s *Series) Intervals() []Interval {
+	intervals := make([]Interval, 0)
+	for i := 0; i < s.Len(); i++ {
+		intervals = append(intervals, s.Interval(i)...)
+	}
+	return intervals
+}
+
+// summary returns summaries of reads and writes of bucket of i-th interval
+func (s *Series) summary(i int, b *bucket) [2]Interval {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	var summary [2]Interval
+	for _, t := range []OpType{ReadOp, WriteOp} {
+		h := b.latency[t]
+		summary[t] = Interval{
+			Time:       (time.Duration(i) * s.interval).Seconds(),
+			Type:       t.String(),
+			Count:      h.Count(),
+			Errors:     b.errors[t],
+			Throughput: float64(h.Count()) / s.interval.Seconds(),
+			Mean:       ms(h.Mean()),
+			P50:        ms(h.Percentile(50)),
+			P95:        ms(h.Percentile(95)),
+			P99:        ms(h.Percentile(99)),
+			Max:        ms(h.Max()),
+		}
+	}
+	return summary
+}
+
+// WriteFile writes all intervals into file, in JSON format if path ends with ".json", otherwise in csv format
+func (s *Series) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if strings.HasSuffix(path, ".json") {
+		err = s.encodeJSON(w)
+	} else {
+		err = s.encodeCSV(w)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (s *Series) encodeJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(s.Intervals())
+}
+
+func (s *Series) encodeCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"time", "type", "count", "errors", "throughput", "mean", "p50", "p95", "p99", "max"})
+	f := func(x float64) string { return strconv.FormatFloat(x, 'f', -1, 64) }
+	for _, i := range s.Intervals() {
+		c.Write([]string{f(i.Time), i.Type, strconv.Itoa(i.Count), strconv.Itoa(i.Errors), f(i.Throughput), f(i.Mean), f(i.P50), f(i.P95), f(i.P99), f(i.Max)})
+	}
+	c.Flush()
+	return c.Error()
+}
@@ -0,0 +1,85 @@
+package paxi
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeries(t *testing.T) {
+	s := NewSeries(100 * time.Millisecond)
+	for i := 1; i <= 100; i++ {
+		s.Add(time.Duration(i)*time.Millisecond, ReadOp, time.Duration(i)*time.Millisecond, nil)
+	}
+	s.Add(150*time.Millisecond, WriteOp, 5*time.Millisecond, nil)
+	s.Add(160*time.Millisecond, WriteOp, time.Second, errors.New("timeout"))
+
+	if s.Len() != 2 {
+		t.Fatalf("series has %d intervals, expected 2", s.Len())
+	}
+	first := s.Interval(0)
+	if r := first[0]; r.Type != "read" || r.Count != 99 || r.Throughput != 990 || r.Mean != 50 || r.P99 != 99 || r.Max != 99 {
+		t.Errorf("reads of first interval %v", r)
+	}
+	if r := first[0]; math.Abs(r.P50-50) > 0.05 || math.Abs(r.P95-95) > 0.1 {
+		t.Errorf("percentiles of first interval %v", r)
+	}
+	if w := first[1]; w.Type != "write" || w.Count != 0 || w.Throughput != 0 {
+		t.Errorf("writes of first interval %v", w)
+	}
+	second := s.Interval(1)
+	if r := second[0]; r.Count != 1 || r.Max != 100 {
+		t.Errorf("reads of second interval %v", r)
+	}
+	if w := second[1]; w.Count != 1 || w.Errors != 1 || w.Mean != 5 || w.Time != 0.1 {
+		t.Errorf("writes of second interval %v", w)
+	}
+
+	dir, err := ioutil.TempDir("", "series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := s.WriteFile(filepath.Join(dir, "series.csv")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "series.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[1], "0,read,99,0,990,50,") || !strings.HasSuffix(lines[1], ",99,99") {
+		t.Errorf("csv series is\n%s", b)
+	}
+
+	if err := s.WriteFile(filepath.Join(dir, "series.json")); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "series.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var intervals []Interval
+	if err := json.Unmarshal(b, &intervals); err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) != 4 || intervals[3] != second[1] {
+		t.Errorf("json series is %v", intervals)
+	}
+
+	// first interval is closed but keeps its summary, and still counts late operations
+	s.Add(250*time.Millisecond, ReadOp, time.Millisecond, nil)
+	s.Add(50*time.Millisecond, ReadOp, time.Millisecond, nil)
+	if s.Len() != 3 || len(s.open) != 2 {
+		t.Fatalf("series has %d intervals of which %d are open", s.Len(), len(s.open))
+	}
+	if r := s.Interval(0)[0]; r.Count != 100 || r.Max != first[0].Max || r.P50 != first[0].P50 {
+		t.Errorf("reads of closed interval %v", r)
+	}
+}
@@ -5,8 +5,16 @@ import (
 	"sync"
 
 	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/abd"
+	"github.com/ailidani/paxi/cas"
+	"github.com/ailidani/paxi/chain"
+	"github.com/ailidani/paxi/epaxos"
 	"github.com/ailidani/paxi/log"
+	"github.com/ailidani/paxi/paxos"
+	"github.com/ailidani/paxi/raft"
 	paxos2bro "github.com/ailidani/paxi/rlpaxos"
+	"github.com/ailidani/paxi/rspaxos"
+	"github.com/ailidani/paxi/wpaxos"
 )
 
 var algorithm = flag.String("algorithm", "paxos", "Distributed algorithm")
@@ -24,8 +32,24 @@ func replica(id paxi.ID) {
 
 	switch *algorithm {
 
+	case "paxos":
+		paxos.NewReplica(id).Run()
 	case "paxos2bro":
 		paxos2bro.NewReplica(id).Run()
+	case "abd":
+		abd.NewReplica(id).Run()
+	case "cas":
+		cas.NewReplica(id).Run()
+	case "rspaxos":
+		rspaxos.NewReplica(id).Run()
+	case "epaxos":
+		epaxos.NewReplica(id).Run()
+	case "wpaxos":
+		wpaxos.NewReplica(id).Run()
+	case "chain":
+		chain.NewReplica(id).Run()
+	case "raft":
+		raft.NewReplica(id).Run()
 	default:
 		panic("Unknown algorithm")
 	}
@@ -0,0 +1,111 @@
+package paxi
+
+// session records the commands applied of one client session. Replicas may apply commands of a session
+// out of command id order, e.g. executing slots ahead of holes or in dependency order,
+// so every applied command id is kept above the gap-free mark until the ids below it are applied
+type session struct {
+	CID     int           // every command id up to CID is applied
+	Reply   Value         // cached reply of command CID
+	Applied map[int]Value // command ids applied above CID with their cached replies
+	Active  int           // slot of the last applied command
+}
+
+// sessions is the client session table kept as part of the replicated state,
+// it makes client retries exactly-once by remembering the applied commands of every client.
+// A client never reuses a session after a failed command, so command ids of a session leave no gaps
+// once all of them are applied, and a session is garbage collected when it expires.
+type sessions struct {
+	table   map[ID]*session
+	timeout int // number of slots after which an idle session expires, never if 0
+	checked int // latest multiple of timeout expiry checked at
+}
+
+func newSessions(timeout int) *sessions {
+	return &sessions{
+		table:   make(map[ID]*session),
+		timeout: timeout,
+	}
+}
+
+// duplicate returns the cached reply and true if command c is already applied
+func (s *sessions) duplicate(c Command) (Value, bool) {
+	if c.ClientID == "" || c.CommandID <= 0 {
+		return nil, false
+	}
+	e, exists := s.table[c.ClientID]
+	if !exists {
+		return nil, false
+	}
+	if c.CommandID == e.CID {
+		return e.Reply, true
+	}
+	if c.CommandID < e.CID {
+		// older command that the client already moved on from, nobody waits for its reply
+		return nil, true
+	}
+	v, applied := e.Applied[c.CommandID]
+	return v, applied
+}
+
+// update records reply of command c applied at slot
+func (s *sessions) update(c Command, reply Value, slot int) {
+	if c.ClientID == "" || c.CommandID <= 0 {
+		return
+	}
+	e, exists := s.table[c.ClientID]
+	if !exists {
+		e = new(session)
+		s.table[c.ClientID] = e
+	}
+	if c.CommandID > e.CID {
+		if e.Applied == nil {
+			e.Applied = make(map[int]Value)
+		}
+		e.Applied[c.CommandID] = reply
+		for {
+			v, applied := e.Applied[e.CID+1]
+			if !applied {
+				break
+			}
+			delete(e.Applied, e.CID+1)
+			e.CID++
+			e.Reply = v
+		}
+	}
+	if slot > e.Active {
+		e.Active = slot
+	}
+}
+
+// copy returns a deep copy of session e
+func (e *session) copy() session {
+	c := *e
+	if e.Applied != nil {
+		c.Applied = make(map[int]Value, len(e.Applied))
+		for cid, v := range e.Applied {
+			c.Applied[cid] = v
+		}
+	}
+	return c
+}
+
+// expire removes sessions idle for more than timeout slots when the gap-free executed slot
+// reaches a multiple of timeout. Sessions are only compared against slots every replica has executed,
+// and a session used at a later slot is never removed, so replicas executing slots in different orders
+// expire the same sessions. Checking only the latest multiple removes the same sessions as checking every one,
+// since a session idle at one multiple is also idle at the next unless it is used again.
+func (s *sessions) expire(slot int) {
+	if s.timeout <= 0 {
+		return
+	}
+	m := slot - slot%s.timeout
+	if m <= s.checked {
+		return
+	}
+	s.checked = m
+	for id, e := range s.table {
+		if m-e.Active > s.timeout {
+			delete(s.table, id)
+		}
+	}
+}
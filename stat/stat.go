@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ailidani/paxi"
+)
+
+var output = flag.String("o", "", "write merged latency histogram into file")
+var percentiles = flag.String("p", "50,95,99,99.9", "comma separated percentiles to print")
+
+// stat merges latency histograms written by benchmark clients and prints their statistics
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Usage: stat [-o merged.json] [-p percentiles] latency...")
+	}
+
+	h := paxi.NewLatencyHistogram()
+	for _, file := range flag.Args() {
+		c, err := paxi.ReadHistogram(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h.Merge(c)
+	}
+
+	fmt.Print(h.Stat())
+	for _, s := range strings.Split(*percentiles, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("p%v = %f\n", p, float64(h.Percentile(p))/1000000.0)
+	}
+
+	if *output != "" {
+		if err := h.WriteFile(*output); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package paxi
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi/log"
+)
+
+func init() {
+	gob.Register(Record{})
+	gob.Register(Snapshot{})
+}
+
+// Record is one command applied to database, logged atomically with the apply progress
+type Record struct {
+	Slot     int     // log slot of the command
+	Executed int     // gap-free executed slot when the command is applied
+	Applied  int     // number of commands applied including this one
+	Command  Command // command with its client session
+	Reply    Value   // reply cached in client session
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("Record {s=%d applied=%d cmd=%v}", r.Slot, r.Applied, r.Command)
+}
+
+// Snapshot is the entire database state up to an applied index
+type Snapshot struct {
+	Slot     int   // gap-free executed slot
+	Ahead    []int // slots executed out of order after Slot
+	Applied  int
+	Data     map[Key]Value
+	Version  map[Key]int
+	Sessions map[ID]session
+}
+
+// Storage is the storage engine backing database state.
+// Database keeps its state in memory and recovers it from storage after restart
+type Storage interface {
+	// Append logs record r, a record is either entirely recovered or not at all
+	Append(r Record) error
+
+	// Save replaces everything logged so far with snapshot s
+	Save(s Snapshot) error
+
+	// Load returns the latest snapshot, nil if none, and records appended after it in order
+	Load() (*Snapshot, []Record, error)
+
+	Close() error
+}
+
+// NewStorage returns the storage engine of node id selected by config
+func NewStorage(id ID) (Storage, error) {
+	switch config.Storage {
+	case "", "memory":
+		return new(memory), nil
+	case "disk":
+		return newDisk(config.StoragePath, id, config.StorageSync)
+	default:
+		log.Fatalf("unknown storage engine %s", config.Storage)
+		return nil, nil
+	}
+}
+
+// memory is the default storage that keeps nothing, state is lost after restart
+type memory struct{}
+
+func (m *memory) Append(r Record) error {
+	return nil
+}
+
+func (m *memory) Save(s Snapshot) error {
+	return nil
+}
+
+func (m *memory) Load() (*Snapshot, []Record, error) {
+	return nil, nil, nil
+}
+
+func (m *memory) Close() error {
+	return nil
+}
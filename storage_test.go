@@ -0,0 +1,101 @@
+package paxi
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskRecover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newDisk(dir, "1.1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDatabase(s)
+	d.snapshot = 4
+	for i := 0; i < 6; i++ {
+		d.ExecuteAt(i, Command{Key: Key(i % 2), Value: Value{byte(i)}, ClientID: "c", CommandID: i + 1})
+	}
+	// slot 8 is executed out of order, reads are not logged
+	d.ExecuteAt(8, Command{Key: 0, Value: Value{8}})
+	d.ExecuteAt(6, Command{Key: 1})
+	d.Close()
+
+	// torn frame at the tail of log
+	file, err := os.OpenFile(filepath.Join(dir, "1.1", diskLog), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{42, 0, 0, 0, 1, 2})
+	file.Close()
+
+	s, err = newDisk(dir, "1.1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = newDatabase(s)
+	if err := d.recover(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if d.applied != 7 || d.Slot() != 5 || !d.ahead[8] {
+		t.Errorf("recovered applied=%d slot=%d ahead=%v, want 7, 5 and [8]", d.applied, d.Slot(), d.ahead)
+	}
+	if v := d.Get(0); !bytes.Equal(v, Value{8}) {
+		t.Errorf("Get(0) = %v, want [8]", v)
+	}
+	// recovered slots replayed by protocol are not executed again
+	d.ExecuteAt(4, Command{Key: 0, Value: Value{44}})
+	d.ExecuteAt(8, Command{Key: 0, Value: Value{88}})
+	if v := d.Get(0); !bytes.Equal(v, Value{8}) || d.applied != 7 {
+		t.Errorf("replayed slots executed again, Get(0) = %v", v)
+	}
+	if v := d.Get(1); !bytes.Equal(v, Value{5}) {
+		t.Errorf("Get(1) = %v, want [5]", v)
+	}
+	// retry of the last command before crash is still deduplicated
+	if v := d.ExecuteAt(6, Command{Key: 1, Value: Value{5}, ClientID: "c", CommandID: 6}); !bytes.Equal(v, Value{3}) {
+		t.Errorf("retry returned %v, want cached [3]", v)
+	}
+	if d.applied != 7 {
+		t.Errorf("retry applied again")
+	}
+	d.ExecuteAt(7, Command{Key: 1, Value: Value{7}})
+	if v := d.Get(1); !bytes.Equal(v, Value{7}) || d.Slot() != 8 {
+		t.Errorf("Get(1) = %v at slot %d, want [7] at 8", v, d.Slot())
+	}
+}
+
+// broken storage fails every append
+type broken struct{ memory }
+
+func (b *broken) Append(r Record) error {
+	return errors.New("disk full")
+}
+
+func TestDatabaseStorageError(t *testing.T) {
+	// replica stops instead of replying a write that is not durable
+	if os.Getenv("PAXI_STORAGE_ERROR") == "1" {
+		d := newDatabase(new(broken))
+		d.ExecuteAt(0, Command{Key: 1, Value: Value("v")})
+		os.Stdout.WriteString("acked\n")
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestDatabaseStorageError")
+	cmd.Env = append(os.Environ(), "PAXI_STORAGE_ERROR=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil || bytes.Contains(out, []byte("acked")) {
+		t.Errorf("write failed to persist is replied, output %s", out)
+	}
+}
@@ -0,0 +1,95 @@
+package paxi
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Event is a write command executed at log slot, delivered to watchers of its key
+type Event struct {
+	Slot    int
+	Command Command
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("Event {s=%d cmd=%v}", e.Slot, e.Command)
+}
+
+// Watcher receives events of keys in range [From, To] from channel C in slot order,
+// an event executed out of order is delivered once every slot before it is executed.
+// C is closed when the watcher stops, or when it falls too far behind.
+type Watcher struct {
+	From Key
+	To   Key
+	C    <-chan Event
+
+	c    chan Event
+	once sync.Once
+	stop func()
+}
+
+func newWatcher(from, to Key, size int) *Watcher {
+	c := make(chan Event, size)
+	return &Watcher{
+		From: from,
+		To:   to,
+		C:    c,
+		c:    c,
+	}
+}
+
+// Stop stops the watcher and closes C
+func (w *Watcher) Stop() {
+	w.once.Do(w.stop)
+}
+
+func (w *Watcher) has(k Key) bool {
+	return w.From <= k && k <= w.To
+}
+
+// watchers is the set of watchers on a database
+type watchers struct {
+	sync.Mutex
+	set map[*Watcher]struct{}
+}
+
+func newWatchers() *watchers {
+	return &watchers{
+		set: make(map[*Watcher]struct{}),
+	}
+}
+
+// add registers a new watcher on key range [from, to]
+func (s *watchers) add(from, to Key) *Watcher {
+	w := newWatcher(from, to, config.ChanBufferSize)
+	w.stop = func() {
+		s.Lock()
+		defer s.Unlock()
+		if _, exists := s.set[w]; exists {
+			delete(s.set, w)
+			close(w.c)
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.set[w] = struct{}{}
+	return w
+}
+
+// publish sends event to every watcher of its key without blocking,
+// a watcher whose buffer is full is dropped so that it cannot stall execution
+func (s *watchers) publish(e Event) {
+	s.Lock()
+	defer s.Unlock()
+	for w := range s.set {
+		if !w.has(e.Command.Key) {
+			continue
+		}
+		select {
+		case w.c <- e:
+		default:
+			delete(s.set, w)
+			close(w.c)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package paxi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDatabaseWatch(t *testing.T) {
+	db := NewDatabase()
+	w := db.Watch(1, 2)
+	db.ExecuteAt(0, Command{Key: 1, Value: Value("a")})
+	db.ExecuteAt(1, Command{Key: 3, Value: Value("b")})
+	db.ExecuteAt(2, Command{Key: 1})
+	db.ExecuteAt(3, Command{Key: 2, Value: Value("c")})
+	w.Stop()
+
+	slots := make([]int, 0)
+	for e := range w.C {
+		slots = append(slots, e.Slot)
+	}
+	if len(slots) != 2 || slots[0] != 0 || slots[1] != 3 {
+		t.Errorf("watched slots %v, want [0 3]", slots)
+	}
+}
+
+func TestDatabaseWatchOutOfOrder(t *testing.T) {
+	db := NewDatabase()
+	w := db.Watch(1, 1)
+	db.ExecuteAt(0, Command{Key: 1, Value: Value("a")})
+	db.ExecuteAt(3, Command{Key: 1, Value: Value("d")})
+	db.ExecuteAt(2, Command{Key: 1, Value: Value("c")})
+	if len(w.C) != 1 {
+		t.Fatalf("%d events published before slot 1 is executed", len(w.C))
+	}
+	db.ExecuteAt(1, Command{Key: 1, Value: Value("b")})
+	w.Stop()
+
+	values := ""
+	for e := range w.C {
+		values += string(e.Command.Value)
+	}
+	if values != "abcd" {
+		t.Errorf("watched values %s, want abcd", values)
+	}
+}
+
+func TestHTTPClientWatch(t *testing.T) {
+	n := &node{
+		id:       "1.1",
+		Database: NewDatabase(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(n.handleWatch))
+	defer server.Close()
+
+	c := NewHTTPClient("1.1")
+	c.HTTP = map[ID]string{"1.1": server.URL}
+	w, err := c.Watch(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// watcher is registered at the server once Watch returns
+	for s := 0; s < 7; s++ {
+		n.ExecuteAt(s, Command{})
+	}
+	n.ExecuteAt(7, Command{Key: 4, Value: Value("x")})
+	n.ExecuteAt(8, Command{Key: 5, Value: Value("y")})
+
+	select {
+	case e := <-w.C:
+		if e.Slot != 8 || e.Command.Key != 5 || string(e.Command.Value) != "y" {
+			t.Errorf("received %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
@@ -0,0 +1,80 @@
+package wpaxos
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/paxos"
+)
+
+func init() {
+	gob.Register(Prepare{})
+	gob.Register(Promise{})
+	gob.Register(Accept{})
+	gob.Register(Accepted{})
+	gob.Register(Commit{})
+	gob.Register(LeaderChange{})
+}
+
+// Prepare phase 1a message of the paxos instance of key
+type Prepare struct {
+	Key paxi.Key
+	paxos.P1a
+}
+
+func (m Prepare) String() string {
+	return fmt.Sprintf("Prepare {key=%d %v}", m.Key, m.P1a)
+}
+
+// Promise phase 1b message of the paxos instance of key
+type Promise struct {
+	Key paxi.Key
+	paxos.P1b
+}
+
+func (m Promise) String() string {
+	return fmt.Sprintf("Promise {key=%d %v}", m.Key, m.P1b)
+}
+
+// Accept phase 2a message of the paxos instance of key
+type Accept struct {
+	Key paxi.Key
+	paxos.P2a
+}
+
+func (m Accept) String() string {
+	return fmt.Sprintf("Accept {key=%d %v}", m.Key, m.P2a)
+}
+
+// Accepted phase 2b message of the paxos instance of key
+type Accepted struct {
+	Key paxi.Key
+	paxos.P2b
+}
+
+func (m Accepted) String() string {
+	return fmt.Sprintf("Accepted {key=%d %v}", m.Key, m.P2b)
+}
+
+// Commit phase 3 message of the paxos instance of key
+type Commit struct {
+	Key paxi.Key
+	paxos.P3
+}
+
+func (m Commit) String() string {
+	return fmt.Sprintf("Commit {key=%d %v}", m.Key, m.P3)
+}
+
+// LeaderChange asks node To to steal the leadership of key from current leader From
+type LeaderChange struct {
+	Key    paxi.Key
+	To     paxi.ID
+	From   paxi.ID
+	Ballot paxi.Ballot
+}
+
+func (m LeaderChange) String() string {
+	return fmt.Sprintf("LeaderChange {key=%d to=%s from=%s b=%v}", m.Key, m.To, m.From, m.Ballot)
+}
@@ -0,0 +1,153 @@
+package wpaxos
+
+import (
+	"flag"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/log"
+	"github.com/ailidani/paxi/paxos"
+)
+
+var fz = flag.Int("fz", 0, "number of zone failures tolerated by flexible grid quorums")
+
+// node scopes paxi.Node to the paxos instance of one key, messages sent are tagged with the key
+type node struct {
+	paxi.Node
+	key paxi.Key
+}
+
+// ExecuteAt executes command in the order of execution across keys, since every key numbers its own slots
+func (n *node) ExecuteAt(slot int, c paxi.Command) paxi.Value {
+	if c.Empty() {
+		return nil
+	}
+	return n.Node.Execute(c)
+}
+
+func (n *node) Send(to paxi.ID, m interface{}) {
+	n.Node.Send(to, n.tag(m))
+}
+
+func (n *node) Broadcast(m interface{}) {
+	n.Node.Broadcast(n.tag(m))
+}
+
+// MulticastQuorum sends to all nodes since thrifty majority is not a grid quorum
+func (n *node) MulticastQuorum(quorum int, m interface{}) {
+	n.Node.Broadcast(n.tag(m))
+}
+
+func (n *node) tag(m interface{}) interface{} {
+	switch m := m.(type) {
+	case paxos.P1a:
+		return Prepare{n.key, m}
+	case paxos.P1b:
+		return Promise{n.key, m}
+	case paxos.P2a:
+		return Accept{n.key, m}
+	case paxos.P2b:
+		return Accepted{n.key, m}
+	case paxos.P3:
+		return Commit{n.key, m}
+	default:
+		log.Errorf("unknown paxos message %v", m)
+		return m
+	}
+}
+
+// Replica of WPaxos runs one multi-paxos instance per key over flexible grid quorums across zones,
+// the leader of each key migrates to the zone chosen by access policy of the key,
+// so that keys are owned by the zones accessing them most.
+type Replica struct {
+	paxi.Node
+	paxos  map[paxi.Key]*paxos.Paxos
+	policy map[paxi.Key]paxi.Policy
+}
+
+// NewReplica generates new WPaxos replica
+func NewReplica(id paxi.ID) *Replica {
+	return newReplica(paxi.NewNode(id))
+}
+
+// newReplica generates new WPaxos replica on node n
+func newReplica(n paxi.Node) *Replica {
+	r := new(Replica)
+	r.Node = n
+	r.paxos = make(map[paxi.Key]*paxos.Paxos)
+	r.policy = make(map[paxi.Key]paxi.Policy)
+	r.Register(paxi.Request{}, r.handleRequest)
+	r.Register(Prepare{}, r.handlePrepare)
+	r.Register(Promise{}, r.handlePromise)
+	r.Register(Accept{}, r.handleAccept)
+	r.Register(Accepted{}, r.handleAccepted)
+	r.Register(Commit{}, r.handleCommit)
+	r.Register(LeaderChange{}, r.handleLeaderChange)
+	return r
+}
+
+// init returns the paxos instance of key k, creates a new one if not exists
+func (r *Replica) init(k paxi.Key) *paxos.Paxos {
+	p, exists := r.paxos[k]
+	if !exists {
+		p = paxos.NewPaxos(&node{r.Node, k}, func(p *paxos.Paxos) {
+			p.Q1 = func(q *paxi.Quorum) bool { return q.FGridQ1(*fz) }
+			p.Q2 = func(q *paxi.Quorum) bool { return q.FGridQ2(*fz) }
+		})
+		r.paxos[k] = p
+		r.policy[k] = paxi.NewPolicy()
+	}
+	return p
+}
+
+// handleRequest proposes request if this node owns the key, otherwise forwards it to the owner.
+// The owner asks the node in another zone to steal the key when policy hits.
+func (r *Replica) handleRequest(m paxi.Request) {
+	log.Debugf("Replica %s received %v\n", r.ID(), m)
+	k := m.Command.Key
+	p := r.init(k)
+	if !p.IsLeader() && p.Ballot() != 0 {
+		go r.Forward(p.Leader(), m)
+		return
+	}
+
+	p.HandleRequest(m)
+	to := r.policy[k].Hit(m.NodeID)
+	if to != "" && to.Zone() != r.ID().Zone() {
+		r.Send(to, LeaderChange{
+			Key:    k,
+			To:     to,
+			From:   r.ID(),
+			Ballot: p.Ballot(),
+		})
+	}
+}
+
+// handleLeaderChange starts phase 1 to steal the key unless leadership has changed since
+func (r *Replica) handleLeaderChange(m LeaderChange) {
+	p := r.init(m.Key)
+	if m.Ballot < p.Ballot() || p.IsLeader() {
+		return
+	}
+	log.Debugf("Replica %s steals key %d from %s", r.ID(), m.Key, m.From)
+	p.P1a()
+}
+
+func (r *Replica) handlePrepare(m Prepare) {
+	r.init(m.Key).HandleP1a(m.P1a)
+}
+
+func (r *Replica) handlePromise(m Promise) {
+	r.init(m.Key).HandleP1b(m.P1b)
+}
+
+func (r *Replica) handleAccept(m Accept) {
+	r.init(m.Key).HandleP2a(m.P2a)
+}
+
+func (r *Replica) handleAccepted(m Accepted) {
+	r.init(m.Key).HandleP2b(m.P2b)
+}
+
+func (r *Replica) handleCommit(m Commit) {
+	r.init(m.Key).HandleP3(m.P3)
+}
@@ -0,0 +1,89 @@
+package wpaxos
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/ailidani/paxi"
+	"github.com/ailidani/paxi/internal/paxitest"
+)
+
+func TestLeaderChange(t *testing.T) {
+	ids := []paxi.ID{"1.1", "1.2", "1.3", "2.1", "2.2", "2.3"}
+	paxitest.Configure(t, ids...)
+	nodes := make(map[paxi.ID]*paxitest.Node)
+	replicas := make(map[paxi.ID]*Replica)
+	for _, id := range ids {
+		nodes[id] = paxitest.NewNode(id)
+		replicas[id] = newReplica(nodes[id])
+		replicas[id].Run()
+	}
+
+	// leader returns the leader of key k known by replica id
+	leader := func(id paxi.ID, k paxi.Key) (l paxi.ID, active bool) {
+		nodes[id].Do(func() {
+			p := replicas[id].init(k)
+			l, active = p.Leader(), p.IsLeader() && p.Ballot().ID() == id
+		})
+		return
+	}
+	await := func(id paxi.ID, k paxi.Key, expected paxi.ID) {
+		paxitest.Eventually(t, func() error {
+			if l, _ := leader(id, k); l != expected {
+				return fmt.Errorf("replica %s follows %s for key %d, expected %s", id, l, k, expected)
+			}
+			return nil
+		})
+	}
+	write := func(id paxi.ID, k paxi.Key, v int) {
+		nodes[id].Call(t, paxi.Command{Key: k, Value: paxi.Value(strconv.Itoa(v))})
+	}
+	read := func(id paxi.ID, k paxi.Key) string {
+		return string(nodes[id].Call(t, paxi.Command{Key: k}).Value)
+	}
+
+	// zone 1 accesses keys 1 and 2 first and owns both
+	write("1.1", 1, 1)
+	write("1.1", 2, 1)
+	for _, id := range ids {
+		await(id, 1, "1.1")
+		await(id, 2, "1.1")
+	}
+
+	// requests of zone 2 for key 1 are forwarded to 1.1 until its policy moves key 1 to zone 2
+	n := paxi.GetConfig().Threshold
+	for v := 2; v <= int(n)+1; v++ {
+		write("2.1", 1, v)
+	}
+	await("2.1", 1, "2.1")
+	if _, active := leader("2.1", 1); !active {
+		t.Fatalf("replica 2.1 is not active leader of key 1")
+	}
+	await("1.1", 1, "2.1")
+	write("2.1", 1, 100)
+
+	// reads from both zones see the latest write, key 2 stays in zone 1
+	if v := read("2.2", 1); v != "100" {
+		t.Errorf("read of key 1 from 2.2 = %q, expected 100", v)
+	}
+	if v := read("1.2", 1); v != "100" {
+		t.Errorf("read of key 1 from 1.2 = %q, expected 100", v)
+	}
+	if l, active := leader("1.1", 2); l != "1.1" || !active {
+		t.Errorf("replica 1.1 lost leadership of key 2 to %s", l)
+	}
+	if v := read("1.3", 2); v != "1" {
+		t.Errorf("read of key 2 from 1.3 = %q, expected 1", v)
+	}
+
+	// committed writes are executed by every replica
+	for _, id := range ids {
+		paxitest.Eventually(t, func() error {
+			if v := nodes[id].Get(1); string(v) != "100" {
+				return fmt.Errorf("replica %s has value %q of key 1, expected 100", id, v)
+			}
+			return nil
+		})
+	}
+}